@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/lockout"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+)
+
+// requireRole lets a request through only if its access token carries at
+// least role. An ApiKey authorization header matching ADMIN_API_KEY counts
+// as admin, for scripts and for bootstrapping the first admin account.
+func (cfg *apiConfig) requireRole(role auth.Role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.validAdminKey(r) {
+			next(w, r)
+			return
+		}
+		userID, userRole, err := cfg.authenticateRole(r)
+		if err != nil {
+			respondWithError(w, r, err)
+			return
+		}
+		if !userRole.AtLeast(role) {
+			loggerFrom(r.Context()).Warn("Insufficient role", "role", userRole, "required", role)
+			respondWithError(w, r, problem.Forbidden("Requires the "+string(role)+" role"))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), actingUserKey, userID)))
+	})
+}
+
+// actingUser returns the user requireRole let through. It reports false for
+// requests authorized by ADMIN_API_KEY.
+func actingUser(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(actingUserKey).(uuid.UUID)
+	return userID, ok
+}
+
+// validAdminKey checks the ApiKey authorization header against
+// ADMIN_API_KEY. With no key configured it never matches.
+func (cfg *apiConfig) validAdminKey(r *http.Request) bool {
+	key, err := auth.GetAPIKey(r.Header)
+	return err == nil && cfg.adminAPIKey != "" &&
+		subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminAPIKey)) == 1
+}
+
+func (cfg *apiConfig) unlockLoginHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+		IP    string `json:"ip" validate:"ip"`
+	}
+	type response struct {
+		Unlocked []string `json:"unlocked"`
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	var keys []string
+	if params.Email != "" {
+		keys = append(keys, lockout.EmailKey(params.Email))
+	}
+	if params.IP != "" {
+		keys = append(keys, lockout.IPKey(params.IP))
+	}
+	if len(keys) == 0 {
+		respondWithError(w, r, problem.Validation(
+			problem.FieldError{Field: "email", Code: "required", Message: "provide an email or ip to unlock"},
+			problem.FieldError{Field: "ip", Code: "required", Message: "provide an email or ip to unlock"},
+		))
+		return
+	}
+
+	unlocked := []string{}
+	for _, key := range keys {
+		cleared, err := cfg.loginGuard.Reset(r.Context(), key)
+		if err != nil {
+			respondWithError(w, r, problem.Internal(fmt.Errorf("clearing login attempts for %s: %w", key, err)))
+			return
+		}
+		if cleared {
+			unlocked = append(unlocked, key)
+		}
+	}
+	loggerFrom(r.Context()).Info("Login lockouts cleared by admin", "keys", unlocked)
+	respondWithJSON(w, http.StatusOK, response{Unlocked: unlocked})
+}
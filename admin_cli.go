@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/config"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/seed"
+)
+
+type adminCommand struct {
+	name string
+	arg  string
+	help string
+	run  func(a *adminCLI, ctx context.Context, args []string) (adminResult, error)
+}
+
+var adminCommands = []adminCommand{
+	{"create-user", "<email>", "create a user with the password read from stdin", (*adminCLI).createUser},
+	{"reset-password", "<user>", "set the password read from stdin and revoke refresh tokens", (*adminCLI).resetPassword},
+	{"grant-red", "<user>", "give the user Chirpy Red", (*adminCLI).grantRed},
+	{"revoke-red", "<user>", "take Chirpy Red away from the user", (*adminCLI).revokeRed},
+	{"revoke-tokens", "<user>", "revoke all of the user's refresh tokens", (*adminCLI).revokeTokens},
+	{"delete-chirp", "<chirp-id>", "delete a chirp", (*adminCLI).deleteChirp},
+	{"export-user", "<user>", "print everything the user owns, without credentials or moderation records", (*adminCLI).exportUser},
+	{"set-role", "<user> <role>", "make the user a user, moderator or admin", (*adminCLI).setRole},
+	{"suspend", "<user> <days> <reason>", "suspend the user for days, or ban them with 0, and revoke refresh tokens", (*adminCLI).suspend},
+	{"unsuspend", "<user>", "lift the user's suspension or ban", (*adminCLI).unsuspend},
+	{"seed", "<seed> <users> <chirps-per-user>", "insert deterministic fake data (dev platform only)", (*adminCLI).seed},
+}
+
+func adminUsage() string {
+	var b strings.Builder
+	b.WriteString("usage: chirpy admin [flags] <command> [-o table|json] <args>\n\ncommands:\n")
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, c := range adminCommands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.arg, c.help)
+	}
+	tw.Flush()
+	b.WriteString("\n<user> is an email address or user ID. Passwords are read from the first line of stdin.")
+	return b.String()
+}
+
+// runAdmin implements the admin subcommands, which operate on the database
+// directly for operators.
+func runAdmin(ctx context.Context, cfg config.Config, stdin io.Reader, stdout io.Writer) error {
+	if len(cfg.Args) == 0 {
+		return errors.New(adminUsage())
+	}
+	var cmd *adminCommand
+	for i := range adminCommands {
+		if adminCommands[i].name == cfg.Args[0] {
+			cmd = &adminCommands[i]
+		}
+	}
+	if cmd == nil {
+		return fmt.Errorf("unknown admin command %q\n%s", cfg.Args[0], adminUsage())
+	}
+
+	fs := flag.NewFlagSet("chirpy admin "+cmd.name, flag.ContinueOnError)
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(cfg.Args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != len(strings.Fields(cmd.arg)) {
+		return fmt.Errorf("usage: chirpy admin %s [-o table|json] %s", cmd.name, cmd.arg)
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("output must be table or json, got %q", *output)
+	}
+	if cfg.DatabaseURL == "" {
+		return errors.New("DB_URL must be set")
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	defer db.Close()
+	a := &adminCLI{db: db, queries: database.New(db), platform: cfg.Platform, stdin: stdin}
+	res, err := cmd.run(a, ctx, fs.Args())
+	if err != nil {
+		return err
+	}
+	return res.write(stdout, *output)
+}
+
+type adminCLI struct {
+	db       *sql.DB
+	queries  *database.Queries
+	platform string
+	stdin    io.Reader
+}
+
+// adminTable is one section of table output.
+type adminTable struct {
+	title  string
+	header []string
+	rows   [][]string
+}
+
+// adminResult is printed either as aligned tables or as data encoded as
+// JSON.
+type adminResult struct {
+	tables []adminTable
+	data   any
+}
+
+func (r adminResult) write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.data)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, t := range r.tables {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		if t.title != "" {
+			fmt.Fprintf(tw, "%s:\n", t.title)
+		}
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+	return tw.Flush()
+}
+
+func userResult(u database.User) adminResult {
+	return adminResult{
+		tables: []adminTable{userTable(u)},
+		data:   toUser(u),
+	}
+}
+
+func userTable(u database.User) adminTable {
+	return adminTable{
+		header: []string{"ID", "EMAIL", "ROLE", "CHIRPY RED", "SUSPENDED", "CREATED AT"},
+		rows: [][]string{{
+			u.ID.String(),
+			u.Email,
+			u.Role,
+			fmt.Sprint(u.IsChirpyRed.Bool),
+			suspendedColumn(userSuspension(u)),
+			formatTime(u.CreatedAt),
+		}},
+	}
+}
+
+func suspendedColumn(s suspension) string {
+	switch {
+	case !s.active(time.Now()):
+		return "-"
+	case s.until.Valid:
+		return "until " + formatTime(s.until.Time)
+	}
+	return "indefinitely"
+}
+
+func toUser(u database.User) User {
+	return User{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		IsChirpyRed: u.IsChirpyRed.Bool,
+		Role:        u.Role,
+		Handle:      nullString(u.Handle),
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// lookupUser finds a user by ID or, failing that, by email.
+func (a *adminCLI) lookupUser(ctx context.Context, ident string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ident); parseErr == nil {
+		user, err = a.queries.GetUserById(ctx, id)
+	} else {
+		user, err = a.queries.GetUserByEmail(ctx, ident)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user %q", ident)
+	}
+	return user, err
+}
+
+func (a *adminCLI) readPassword() (string, error) {
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("error reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+func (a *adminCLI) createUser(ctx context.Context, args []string) (adminResult, error) {
+	email := args[0]
+	if !request.IsEmail(email) {
+		return adminResult{}, fmt.Errorf("invalid email %q", email)
+	}
+	password, err := a.readPassword()
+	if err != nil {
+		return adminResult{}, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return adminResult{}, err
+	}
+	user, err := a.queries.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error creating user: %w", err)
+	}
+	return userResult(user), nil
+}
+
+func (a *adminCLI) resetPassword(ctx context.Context, args []string) (adminResult, error) {
+	user, err := a.lookupUser(ctx, args[0])
+	if err != nil {
+		return adminResult{}, err
+	}
+	password, err := a.readPassword()
+	if err != nil {
+		return adminResult{}, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return adminResult{}, err
+	}
+	err = a.queries.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error setting password: %w", err)
+	}
+	// Sessions opened with the old password should not outlive it.
+	return a.revokeTokens(ctx, []string{user.ID.String()})
+}
+
+func (a *adminCLI) grantRed(ctx context.Context, args []string) (adminResult, error) {
+	return a.setRed(ctx, args[0], true)
+}
+
+func (a *adminCLI) revokeRed(ctx context.Context, args []string) (adminResult, error) {
+	return a.setRed(ctx, args[0], false)
+}
+
+func (a *adminCLI) setRed(ctx context.Context, ident string, red bool) (adminResult, error) {
+	user, err := a.lookupUser(ctx, ident)
+	if err != nil {
+		return adminResult{}, err
+	}
+	err = a.queries.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{
+		ID:          user.ID,
+		IsChirpyRed: sql.NullBool{Bool: red, Valid: true},
+	})
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error updating Chirpy Red: %w", err)
+	}
+	user.IsChirpyRed = sql.NullBool{Bool: red, Valid: true}
+	return userResult(user), nil
+}
+
+func (a *adminCLI) setRole(ctx context.Context, args []string) (adminResult, error) {
+	role, err := auth.ParseRole(args[1])
+	if err != nil {
+		return adminResult{}, err
+	}
+	user, err := a.lookupUser(ctx, args[0])
+	if err != nil {
+		return adminResult{}, err
+	}
+	err = a.queries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: string(role),
+	})
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error setting role: %w", err)
+	}
+	user.Role = string(role)
+	return userResult(user), nil
+}
+
+func (a *adminCLI) revokeTokens(ctx context.Context, args []string) (adminResult, error) {
+	user, err := a.lookupUser(ctx, args[0])
+	if err != nil {
+		return adminResult{}, err
+	}
+	n, err := a.queries.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+	return adminResult{
+		tables: []adminTable{{
+			header: []string{"USER ID", "EMAIL", "TOKENS REVOKED"},
+			rows:   [][]string{{user.ID.String(), user.Email, fmt.Sprint(n)}},
+		}},
+		data: map[string]any{"user_id": user.ID, "email": user.Email, "tokens_revoked": n},
+	}, nil
+}
+
+func (a *adminCLI) deleteChirp(ctx context.Context, args []string) (adminResult, error) {
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return adminResult{}, fmt.Errorf("invalid chirp ID %q", args[0])
+	}
+	chirp, err := a.queries.GrabChirp(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return adminResult{}, fmt.Errorf("no chirp %s", id)
+	}
+	if err != nil {
+		return adminResult{}, err
+	}
+	if err := a.queries.DeleteChirp(ctx, id); err != nil {
+		return adminResult{}, fmt.Errorf("error deleting chirp: %w", err)
+	}
+	return adminResult{
+		tables: []adminTable{chirpTable("", []database.Chirp{chirp})},
+		data:   toChirp(chirp),
+	}, nil
+}
+
+func chirpTable(title string, chirps []database.Chirp) adminTable {
+	t := adminTable{title: title, header: []string{"ID", "USER ID", "CREATED AT", "STATUS", "BODY"}}
+	for _, c := range chirps {
+		t.rows = append(t.rows, []string{c.ID.String(), c.UserID.String(), formatTime(c.CreatedAt), c.Status, c.Body})
+	}
+	return t
+}
+
+// exportUser prints everything the user owns: the account and profile,
+// chirps, drafts, media, follows, blocks, mutes, reposts and sessions.
+// Password hashes and refresh token values are left out because they are
+// credentials. Reports, moderation actions and login attempts are also left
+// out; they are moderation records, not the user's content.
+func (a *adminCLI) exportUser(ctx context.Context, args []string) (adminResult, error) {
+	type exportedUser struct {
+		User
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
+		AvatarURL   string `json:"avatar_url"`
+	}
+	type upload struct {
+		Media
+		Kind    string     `json:"kind"`
+		ChirpID *uuid.UUID `json:"chirp_id"`
+	}
+	type repost struct {
+		ChirpID   uuid.UUID `json:"chirp_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	type session struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+	type export struct {
+		User     exportedUser `json:"user"`
+		Chirps   []Chirp      `json:"chirps"`
+		Drafts   []Draft      `json:"drafts"`
+		Media    []upload     `json:"media"`
+		Follows  []Relation   `json:"follows"`
+		Blocks   []Relation   `json:"blocks"`
+		Mutes    []Relation   `json:"mutes"`
+		Reposts  []repost     `json:"reposts"`
+		Sessions []session    `json:"sessions"`
+	}
+
+	user, err := a.lookupUser(ctx, args[0])
+	if err != nil {
+		return adminResult{}, err
+	}
+	chirps, err := a.queries.ListChirpsByUser(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading chirps: %w", err)
+	}
+	drafts, err := a.queries.ListDrafts(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading drafts: %w", err)
+	}
+	media, err := a.queries.ListMediaByUser(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading media: %w", err)
+	}
+	follows, err := a.queries.ListFollows(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading follows: %w", err)
+	}
+	blocks, err := a.queries.ListBlocks(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading blocks: %w", err)
+	}
+	mutes, err := a.queries.ListMutes(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading mutes: %w", err)
+	}
+	reposts, err := a.queries.ListRepostsByUser(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading reposts: %w", err)
+	}
+	tokens, err := a.queries.ListRefreshTokensByUser(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error loading sessions: %w", err)
+	}
+
+	out := export{
+		User: exportedUser{
+			User:        toUser(user),
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			AvatarURL:   user.AvatarUrl,
+		},
+		Chirps:   []Chirp{},
+		Drafts:   []Draft{},
+		Media:    []upload{},
+		Follows:  []Relation{},
+		Blocks:   []Relation{},
+		Mutes:    []Relation{},
+		Reposts:  []repost{},
+		Sessions: []session{},
+	}
+	for _, c := range chirps {
+		out.Chirps = append(out.Chirps, toChirp(c))
+	}
+	draftRows := adminTable{title: "Drafts", header: []string{"ID", "UPDATED AT", "BODY"}}
+	for _, d := range drafts {
+		out.Drafts = append(out.Drafts, toDraft(d))
+		draftRows.rows = append(draftRows.rows, []string{d.ID.String(), formatTime(d.UpdatedAt), d.Body})
+	}
+	mediaRows := adminTable{title: "Media", header: []string{"ID", "KIND", "CHIRP ID", "CONTENT TYPE", "SIZE", "CREATED AT"}}
+	for _, m := range media {
+		out.Media = append(out.Media, upload{Media: toMedia(m), Kind: m.Kind, ChirpID: nullUUID(m.ChirpID)})
+		chirpID := "-"
+		if m.ChirpID.Valid {
+			chirpID = m.ChirpID.UUID.String()
+		}
+		mediaRows.rows = append(mediaRows.rows, []string{m.ID.String(), m.Kind, chirpID, m.ContentType, fmt.Sprint(m.SizeBytes), formatTime(m.CreatedAt)})
+	}
+	for _, f := range follows {
+		out.Follows = append(out.Follows, Relation{UserID: f.FollowedID, CreatedAt: f.CreatedAt})
+	}
+	for _, b := range blocks {
+		out.Blocks = append(out.Blocks, Relation{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	for _, m := range mutes {
+		out.Mutes = append(out.Mutes, Relation{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	repostRows := adminTable{title: "Reposts", header: []string{"CHIRP ID", "CREATED AT"}}
+	for _, r := range reposts {
+		out.Reposts = append(out.Reposts, repost{ChirpID: r.ChirpID, CreatedAt: r.CreatedAt})
+		repostRows.rows = append(repostRows.rows, []string{r.ChirpID.String(), formatTime(r.CreatedAt)})
+	}
+	sessions := adminTable{title: "Sessions", header: []string{"CREATED AT", "EXPIRES AT", "REVOKED AT"}}
+	for _, t := range tokens {
+		s := session{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+		revoked := "-"
+		if t.RevokedAt.Valid {
+			s.RevokedAt = &t.RevokedAt.Time
+			revoked = formatTime(t.RevokedAt.Time)
+		}
+		out.Sessions = append(out.Sessions, s)
+		sessions.rows = append(sessions.rows, []string{formatTime(t.CreatedAt), formatTime(t.ExpiresAt), revoked})
+	}
+
+	account := userTable(user)
+	account.title = "User"
+	profile := adminTable{
+		title:  "Profile",
+		header: []string{"HANDLE", "DISPLAY NAME", "BIO", "AVATAR URL"},
+		rows:   [][]string{{user.Handle.String, user.DisplayName, user.Bio, user.AvatarUrl}},
+	}
+	return adminResult{
+		tables: []adminTable{
+			account,
+			profile,
+			chirpTable("Chirps", chirps),
+			draftRows,
+			mediaRows,
+			relationTable("Follows", out.Follows),
+			relationTable("Blocks", out.Blocks),
+			relationTable("Mutes", out.Mutes),
+			repostRows,
+			sessions,
+		},
+		data: out,
+	}, nil
+}
+
+func relationTable(title string, relations []Relation) adminTable {
+	t := adminTable{title: title, header: []string{"USER ID", "CREATED AT"}}
+	for _, r := range relations {
+		t.rows = append(t.rows, []string{r.UserID.String(), formatTime(r.CreatedAt)})
+	}
+	return t
+}
+
+func (a *adminCLI) seed(ctx context.Context, args []string) (adminResult, error) {
+	if a.platform != "dev" {
+		return adminResult{}, errors.New("seeding is only allowed when PLATFORM is dev")
+	}
+	var nums [3]int
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return adminResult{}, fmt.Errorf("invalid number %q", arg)
+		}
+		nums[i] = n
+	}
+	if nums[0] < 0 {
+		return adminResult{}, errors.New("seed must not be negative")
+	}
+	opts := seed.Options{Seed: uint64(nums[0]), Users: nums[1], ChirpsPerUser: nums[2], FollowsPerUser: defaultSeedFollowsPerUser}
+	if err := validateSeedOptions(opts); err != nil {
+		return adminResult{}, err
+	}
+
+	counts, err := seed.Load(ctx, a.db, seed.Generate(opts))
+	if err != nil {
+		return adminResult{}, err
+	}
+	return adminResult{
+		tables: []adminTable{{
+			header: []string{"SEED", "USERS INSERTED", "FOLLOWS INSERTED", "CHIRPS INSERTED", "PASSWORD"},
+			rows:   [][]string{{fmt.Sprint(opts.Seed), fmt.Sprint(counts.Users), fmt.Sprint(counts.Follows), fmt.Sprint(counts.Chirps), seed.Password}},
+		}},
+		data: map[string]any{
+			"seed":             opts.Seed,
+			"users_inserted":   counts.Users,
+			"follows_inserted": counts.Follows,
+			"chirps_inserted":  counts.Chirps,
+			"password":         seed.Password,
+		},
+	}, nil
+}
+
+func (a *adminCLI) suspend(ctx context.Context, args []string) (adminResult, error) {
+	days, err := strconv.Atoi(args[1])
+	if err != nil || days < 0 {
+		return adminResult{}, fmt.Errorf("days must be a whole number of days or 0, got %q", args[1])
+	}
+	var until *time.Time
+	if days > 0 {
+		t := time.Now().UTC().Add(time.Duration(days) * 24 * time.Hour)
+		until = &t
+	}
+	return a.moderateUser(ctx, args[0], actionSuspend, args[2], func(q *database.Queries, userID uuid.UUID) error {
+		return suspendUser(ctx, q, userID, until, args[2])
+	})
+}
+
+func (a *adminCLI) unsuspend(ctx context.Context, args []string) (adminResult, error) {
+	return a.moderateUser(ctx, args[0], actionUnsuspend, "", func(q *database.Queries, userID uuid.UUID) error {
+		n, err := q.UnsuspendUser(ctx, userID)
+		if err == nil && n == 0 {
+			return fmt.Errorf("%s is not suspended", args[0])
+		}
+		return err
+	})
+}
+
+// moderateUser applies a moderation action to a user and records it in the
+// audit trail, with no moderator, in one transaction.
+func (a *adminCLI) moderateUser(ctx context.Context, ident, action, reason string, apply func(*database.Queries, uuid.UUID) error) (adminResult, error) {
+	user, err := a.lookupUser(ctx, ident)
+	if err != nil {
+		return adminResult{}, err
+	}
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return adminResult{}, err
+	}
+	defer tx.Rollback()
+	q := a.queries.WithTx(tx)
+	if err := apply(q, user.ID); err != nil {
+		return adminResult{}, err
+	}
+	_, err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		Action: action,
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Reason: reason,
+	})
+	if err != nil {
+		return adminResult{}, fmt.Errorf("error recording moderation action: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return adminResult{}, err
+	}
+	user, err = a.queries.GetUserById(ctx, user.ID)
+	if err != nil {
+		return adminResult{}, err
+	}
+	return userResult(user), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+)
+
+func TestAdminResultWrite(t *testing.T) {
+	res := adminResult{
+		tables: []adminTable{{
+			title:  "Sessions",
+			header: []string{"A", "LONGER"},
+			rows:   [][]string{{"value", "x"}},
+		}},
+		data: map[string]int{"revoked": 2},
+	}
+
+	var table bytes.Buffer
+	if err := res.write(&table, "table"); err != nil {
+		t.Fatal(err)
+	}
+	want := "Sessions:\nA      LONGER\nvalue  x\n"
+	if table.String() != want {
+		t.Errorf("table output = %q, want %q", table.String(), want)
+	}
+
+	var js bytes.Buffer
+	if err := res.write(&js, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if js.String() != "{\n  \"revoked\": 2\n}\n" {
+		t.Errorf("json output = %q", js.String())
+	}
+}
+
+func TestAdminReadPassword(t *testing.T) {
+	a := &adminCLI{stdin: strings.NewReader("s3cret pass\r\nignored\n")}
+	got, err := a.readPassword()
+	if err != nil || got != "s3cret pass" {
+		t.Errorf("readPassword = %q, %v", got, err)
+	}
+
+	a = &adminCLI{stdin: strings.NewReader("")}
+	if _, err := a.readPassword(); err == nil {
+		t.Error("readPassword accepted empty stdin")
+	}
+}
+
+func TestExportUser(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	userID, _ := testUser(t, cfg, "exported@example.com")
+	otherID, _ := testUser(t, cfg, "other@example.com")
+	chirp := testChirp(t, cfg, otherID, "repost me", uuid.Nil)
+	testChirp(t, cfg, userID, "my chirp", uuid.Nil)
+	if _, err := cfg.database.CreateDraft(ctx, database.CreateDraftParams{UserID: userID, Body: "my draft"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.database.CreateBlock(ctx, database.CreateBlockParams{BlockerID: userID, BlockedID: otherID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.database.CreateRepost(ctx, database.CreateRepostParams{UserID: userID, ChirpID: chirp.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &adminCLI{db: cfg.db, queries: cfg.database}
+	result, err := a.exportUser(ctx, []string{userID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := result.write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{"chirps": 1, "drafts": 1, "media": 0, "follows": 0, "blocks": 1, "mutes": 0, "reposts": 1, "sessions": 0}
+	for key, expected := range counts {
+		var items []json.RawMessage
+		if err := json.Unmarshal(out[key], &items); err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if len(items) != expected {
+			t.Errorf("%s has %d entries, expected %d", key, len(items), expected)
+		}
+	}
+	var user map[string]any
+	if err := json.Unmarshal(out["user"], &user); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"email", "display_name", "bio", "avatar_url"} {
+		if _, ok := user[key]; !ok {
+			t.Errorf("user has no %s", key)
+		}
+	}
+}
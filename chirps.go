@@ -1,54 +1,133 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/chirptext"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/moderation"
+	"github.com/iahta/chirpy/internal/problem"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Body          string     `json:"body"`
+	UserID        uuid.UUID  `json:"user_id"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	Media         []Media    `json:"media,omitempty"`
+	QuotedChirpID *uuid.UUID `json:"quoted_chirp_id,omitempty"`
+	// QuotedChirp is missing when the quoted chirp was deleted or is not
+	// visible to the viewer.
+	QuotedChirp *Chirp `json:"quoted_chirp,omitempty"`
+	RepostCount int64  `json:"repost_count"`
+	Reposted    bool   `json:"reposted"`
 }
 
-func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
-	authHeader, err := auth.GetBearerToken(r.Header)
+func toChirp(c database.Chirp) Chirp {
+	return Chirp{
+		ID:            c.ID,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+		Body:          c.Body,
+		UserID:        c.UserID,
+		Status:        c.Status,
+		PublishAt:     nullTimePtr(c.PublishAt),
+		QuotedChirpID: nullUUID(c.QuotedChirpID),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+const (
+	chirpPublished = "published"
+	chirpHeld      = "held"
+	chirpHidden    = "hidden"
+	chirpScheduled = "scheduled"
+)
+
+// screenChirp normalizes and length-checks a chirp body and runs it
+// through the moderation rules, returning the text to store and whether
+// it is published or held. Rejected bodies are validation problems.
+func (cfg *apiConfig) screenChirp(ctx context.Context, body string) (string, string, error) {
+	body = chirptext.Normalize(body)
+	switch n := chirptext.Length(body); {
+	case n == 0:
+		return "", "", problem.Validation(problem.FieldError{
+			Field:   "body",
+			Code:    "required",
+			Message: "is required",
+		})
+	case n > chirptext.MaxLength:
+		return "", "", problem.Validation(problem.FieldError{
+			Field:   "body",
+			Code:    "too_long",
+			Message: fmt.Sprintf("must be at most %d characters", chirptext.MaxLength),
+		})
+	}
+	engine, err := cfg.moderation.Engine(ctx)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Missing Authorization")
-		return
+		if engine == nil {
+			return "", "", problem.Internal(err)
+		}
+		loggerFrom(ctx).Warn("Using stale moderation rules", "error", err)
 	}
-	userID, err := auth.ValidateJWT(authHeader, cfg.jwtSecret)
+	screened := engine.Apply(body)
+	switch screened.Action {
+	case moderation.ActionReject:
+		loggerFrom(ctx).Info("Chirp rejected by moderation rules", "rules", ruleIDs(screened.Matched))
+		return "", "", problem.Validation(problem.FieldError{
+			Field:   "body",
+			Code:    "rejected_content",
+			Message: "contains content that is not allowed",
+		})
+	case moderation.ActionHold:
+		loggerFrom(ctx).Info("Chirp held for review by moderation rules", "rules", ruleIDs(screened.Matched))
+		return screened.Text, chirpHeld, nil
+	}
+	return screened.Text, chirpPublished, nil
+}
+
+func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "Invalid Credentials")
+		respondWithError(w, r, err)
 		return
 	}
-	chirpID := r.PathValue("chirpID")
-	if chirpID == "" {
-		respondWithError(w, http.StatusNotFound, "Chirp ID is missing in the request path")
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-	parsedChirp, err := uuid.Parse(chirpID)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid chirpID format. Ensure it is a valid UUID")
+	chirp, err := cfg.database.GrabChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Chirp not found"))
 		return
 	}
-	chirp, err := cfg.database.GrabChirp(r.Context(), parsedChirp)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Chirp Not found")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 	if chirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "Only chirp authors can delete chirps")
+		respondWithError(w, r, problem.Forbidden("Only chirp authors can delete chirps"))
 		return
 	}
-	err = cfg.database.DeleteChirp(r.Context(), parsedChirp)
+	err = cfg.database.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Unable to delete chirp")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 	respondWithJSON(w, http.StatusNoContent, nil)
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/seed"
+)
+
+const (
+	defaultSeedUsers          = 20
+	defaultSeedChirpsPerUser  = 10
+	defaultSeedFollowsPerUser = 5
+	maxSeedUsers              = 10000
+	maxSeedChirpsPerUser      = 1000
+	maxSeedFollowsPerUser     = 1000
+)
+
+// defaultReset is what POST /admin/reset clears when no tables are named.
+// The moderation audit trail goes with the users it refers to.
+var defaultReset = []string{"users", "moderation_actions", "hits"}
+
+// resetters maps the names accepted by POST /admin/reset to what they
+// clear, returning how many rows (or hits) were removed. Deleting users
+// also deletes their chirps, follows, reports and refresh tokens, but not
+// the moderation_actions that mention them.
+func (cfg *apiConfig) resetters() map[string]func(context.Context) (int64, error) {
+	return map[string]func(context.Context) (int64, error){
+		"users":              cfg.database.DeleteUsers,
+		"chirps":             cfg.database.DeleteChirps,
+		"follows":            cfg.database.DeleteFollows,
+		"refresh_tokens":     cfg.database.DeleteRefreshTokens,
+		"jobs":               cfg.database.DeleteJobs,
+		"rate_limits":        cfg.database.DeleteRateLimitBuckets,
+		"login_attempts":     cfg.database.DeleteLoginAttempts,
+		"reports":            cfg.database.DeleteReports,
+		"moderation_actions": cfg.database.DeleteModerationActions,
+		"moderation_rules": func(ctx context.Context) (int64, error) {
+			n, err := cfg.database.DeleteModerationRules(ctx)
+			cfg.moderation.Invalidate()
+			return n, err
+		},
+		"hits": func(context.Context) (int64, error) {
+			return int64(cfg.fileserverHits.Swap(0)), nil
+		},
+	}
+}
+
+func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Tables []string `json:"tables"`
+	}
+	type response struct {
+		Reset map[string]int64 `json:"reset"`
+	}
+	if cfg.platform != "dev" {
+		respondWithError(w, r, problem.Forbidden("Reset is only allowed in dev environment"))
+		return
+	}
+
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil && !errors.Is(err, request.ErrEmptyBody) {
+		respondWithError(w, r, err)
+		return
+	}
+	if len(params.Tables) == 0 {
+		params.Tables = defaultReset
+	}
+	resetters := cfg.resetters()
+	for _, table := range params.Tables {
+		if _, ok := resetters[table]; !ok {
+			names := make([]string, 0, len(resetters))
+			for name := range resetters {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			respondWithError(w, r, problem.Validation(problem.FieldError{
+				Field:   "tables",
+				Code:    "unknown_table",
+				Message: fmt.Sprintf("unknown table %q, expected one of %s", table, strings.Join(names, ", ")),
+			}))
+			return
+		}
+	}
+
+	reset := map[string]int64{}
+	for _, table := range params.Tables {
+		n, err := resetters[table](r.Context())
+		if err != nil {
+			respondWithError(w, r, problem.Internal(fmt.Errorf("resetting %s: %w", table, err)))
+			return
+		}
+		reset[table] = n
+	}
+	loggerFrom(r.Context()).Info("Dev data reset", "reset", reset)
+	respondWithJSON(w, http.StatusOK, response{Reset: reset})
+}
+
+func (cfg *apiConfig) seedHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Seed           uint64 `json:"seed"`
+		Users          *int   `json:"users"`
+		ChirpsPerUser  *int   `json:"chirps_per_user"`
+		FollowsPerUser *int   `json:"follows_per_user"`
+	}
+	type response struct {
+		Seed            uint64 `json:"seed"`
+		UsersInserted   int64  `json:"users_inserted"`
+		FollowsInserted int64  `json:"follows_inserted"`
+		ChirpsInserted  int64  `json:"chirps_inserted"`
+		Password        string `json:"password"`
+	}
+	if cfg.platform != "dev" {
+		respondWithError(w, r, problem.Forbidden("Seeding is only allowed in dev environment"))
+		return
+	}
+
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil && !errors.Is(err, request.ErrEmptyBody) {
+		respondWithError(w, r, err)
+		return
+	}
+	opts := seed.Options{
+		Seed:           params.Seed,
+		Users:          defaultSeedUsers,
+		ChirpsPerUser:  defaultSeedChirpsPerUser,
+		FollowsPerUser: defaultSeedFollowsPerUser,
+	}
+	if params.Users != nil {
+		opts.Users = *params.Users
+	}
+	if params.ChirpsPerUser != nil {
+		opts.ChirpsPerUser = *params.ChirpsPerUser
+	}
+	if params.FollowsPerUser != nil {
+		opts.FollowsPerUser = *params.FollowsPerUser
+	}
+	if err := validateSeedOptions(opts); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	counts, err := seed.Load(r.Context(), cfg.db, seed.Generate(opts))
+	if err != nil {
+		respondWithError(w, r, problem.Internal(fmt.Errorf("seeding database: %w", err)))
+		return
+	}
+	loggerFrom(r.Context()).Info("Dev data seeded", "seed", opts.Seed, "users", counts.Users, "follows", counts.Follows, "chirps", counts.Chirps)
+	respondWithJSON(w, http.StatusOK, response{
+		Seed:            opts.Seed,
+		UsersInserted:   counts.Users,
+		FollowsInserted: counts.Follows,
+		ChirpsInserted:  counts.Chirps,
+		Password:        seed.Password,
+	})
+}
+
+func validateSeedOptions(opts seed.Options) error {
+	var fields []problem.FieldError
+	if opts.Users < 0 || opts.Users > maxSeedUsers {
+		fields = append(fields, problem.FieldError{
+			Field:   "users",
+			Code:    "out_of_range",
+			Message: fmt.Sprintf("must be between 0 and %d", maxSeedUsers),
+		})
+	}
+	if opts.ChirpsPerUser < 0 || opts.ChirpsPerUser > maxSeedChirpsPerUser {
+		fields = append(fields, problem.FieldError{
+			Field:   "chirps_per_user",
+			Code:    "out_of_range",
+			Message: fmt.Sprintf("must be between 0 and %d", maxSeedChirpsPerUser),
+		})
+	}
+	if opts.FollowsPerUser < 0 || opts.FollowsPerUser > maxSeedFollowsPerUser {
+		fields = append(fields, problem.FieldError{
+			Field:   "follows_per_user",
+			Code:    "out_of_range",
+			Message: fmt.Sprintf("must be between 0 and %d", maxSeedFollowsPerUser),
+		})
+	}
+	if len(fields) > 0 {
+		return problem.Validation(fields...)
+	}
+	return nil
+}
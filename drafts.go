@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
+)
+
+// Draft is an unpublished chirp body. Drafts are private to their author
+// and are not held to the chirp length limit until they are published.
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+func toDraft(d database.Draft) Draft {
+	return Draft{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		Body:      d.Body,
+	}
+}
+
+var errDraftNotFound = problem.NotFound("Draft not found")
+
+// draftTarget authenticates the request and reads the draft ID from the
+// path.
+func (cfg *apiConfig) draftTarget(r *http.Request) (userID, draftID uuid.UUID, err error) {
+	userID, err = cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	draftID, err = pathUUID(r, "draftID")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, draftID, nil
+}
+
+func (cfg *apiConfig) createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body" validate:"max=10000"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	draft, err := cfg.database.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: userID,
+		Body:   params.Body,
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toDraft(draft))
+}
+
+// listDraftsHandler lists the user's drafts, most recently edited first.
+func (cfg *apiConfig) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rows, err := cfg.database.ListDrafts(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	drafts := make([]Draft, len(rows))
+	for i, d := range rows {
+		drafts[i] = toDraft(d)
+	}
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) getDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, err := cfg.draftTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	draft, err := cfg.database.GetDraft(r.Context(), database.GetDraftParams{ID: draftID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errDraftNotFound)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toDraft(draft))
+}
+
+func (cfg *apiConfig) updateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body" validate:"max=10000"`
+	}
+
+	userID, draftID, err := cfg.draftTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	draft, err := cfg.database.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draftID,
+		UserID: userID,
+		Body:   params.Body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errDraftNotFound)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toDraft(draft))
+}
+
+func (cfg *apiConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, err := cfg.draftTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	n, err := cfg.database.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draftID, UserID: userID})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if n == 0 {
+		respondWithError(w, r, errDraftNotFound)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// publishDraftHandler turns a draft into a chirp. The draft is removed and
+// the chirp created in one transaction, so a draft is published at most
+// once and survives a body that fails validation or moderation.
+func (cfg *apiConfig) publishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, err := cfg.draftTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+
+	draft, err := q.TakeDraft(r.Context(), database.TakeDraftParams{ID: draftID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errDraftNotFound)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	body, status, err := cfg.screenChirp(r.Context(), draft.Body)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirp, err := q.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:   body,
+		UserID: userID,
+		Status: status,
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	cfg.metrics.ChirpsCreated.Inc()
+	response := []Chirp{toChirp(chirp)}
+	if err := cfg.decorateChirps(r.Context(), userID, response); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response[0])
+}
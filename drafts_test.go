@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/moderation"
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func TestPublishDraftKeepsRejectedDraft(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	userID, token := testUser(t, cfg, "drafter@example.com")
+
+	rule, err := cfg.database.CreateModerationRule(ctx, database.CreateModerationRuleParams{
+		Kind:    string(moderation.KindWord),
+		Pattern: "sharbertdraft",
+		Action:  string(moderation.ActionReject),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cfg.database.DeleteModerationRule(ctx, rule.ID) })
+
+	testCases := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"too long", strings.Repeat("a", 141), "too_long"},
+		{"rejected by moderation", "this is sharbertdraft", "rejected_content"},
+	}
+	for _, tc := range testCases {
+		body, _ := json.Marshal(map[string]string{"body": tc.body})
+		rec := serve(cfg.createDraftHandler, http.MethodPost, token, string(body))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: create status = %d: %s", tc.name, rec.Code, rec.Body)
+		}
+		var draft Draft
+		if err := json.Unmarshal(rec.Body.Bytes(), &draft); err != nil {
+			t.Fatal(err)
+		}
+
+		rec = serve(cfg.publishDraftHandler, http.MethodPost, token, "", "draftID", draft.ID.String())
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: publish status = %d, expected %d", tc.name, rec.Code, http.StatusBadRequest)
+		}
+		var p problem.Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Errors) != 1 || p.Errors[0].Field != "body" || p.Errors[0].Code != tc.code {
+			t.Errorf("%s: errors = %+v, expected body %s", tc.name, p.Errors, tc.code)
+		}
+		kept, err := cfg.database.GetDraft(ctx, database.GetDraftParams{ID: draft.ID, UserID: userID})
+		if err != nil || kept.Body != tc.body {
+			t.Errorf("%s: draft after failed publish = %+v, %v", tc.name, kept, err)
+		}
+	}
+}
+
+func TestPublishDraft(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	userID, token := testUser(t, cfg, "publisher@example.com")
+	draft, err := cfg.database.CreateDraft(ctx, database.CreateDraftParams{UserID: userID, Body: "ready to go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherToken := testUser(t, cfg, "someone-else@example.com")
+	if rec := serve(cfg.publishDraftHandler, http.MethodPost, otherToken, "", "draftID", draft.ID.String()); rec.Code != http.StatusNotFound {
+		t.Errorf("publishing another user's draft: status = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec := serve(cfg.publishDraftHandler, http.MethodPost, token, "", "draftID", draft.ID.String())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publish status = %d: %s", rec.Code, rec.Body)
+	}
+	var chirp Chirp
+	if err := json.Unmarshal(rec.Body.Bytes(), &chirp); err != nil {
+		t.Fatal(err)
+	}
+	if chirp.Body != "ready to go" || chirp.UserID != userID || chirp.Status != chirpPublished {
+		t.Errorf("published chirp = %+v", chirp)
+	}
+	if _, err := cfg.database.GetDraft(ctx, database.GetDraftParams{ID: draft.ID, UserID: userID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("draft after publish: err = %v, expected sql.ErrNoRows", err)
+	}
+	if rec := serve(cfg.publishDraftHandler, http.MethodPost, token, "", "draftID", draft.ID.String()); rec.Code != http.StatusNotFound {
+		t.Errorf("publishing twice: status = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+}
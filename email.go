@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/iahta/chirpy/internal/config"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/jobs"
+	"github.com/iahta/chirpy/internal/mailer"
+)
+
+const jobSendEmail = "send_email"
+
+func newMailer(cfg config.MailConfig) mailer.Mailer {
+	if cfg.Driver == "smtp" {
+		return mailer.SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	}
+	return mailer.LogMailer{}
+}
+
+// sendEmail queues msg for delivery by the job runner so handlers never
+// wait on the mail server.
+func (cfg *apiConfig) sendEmail(ctx context.Context, msg mailer.Message) error {
+	_, err := jobs.Enqueue(ctx, cfg.database, jobSendEmail, msg, jobs.EnqueueOptions{})
+	return err
+}
+
+func (cfg *apiConfig) sendEmailJob(ctx context.Context, job database.Job) error {
+	var msg mailer.Message
+	if err := json.Unmarshal(job.Payload, &msg); err != nil {
+		return jobs.Permanent(err)
+	}
+	return cfg.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/iahta/chirpy/sql/schema"
+)
+
+const (
+	readinessTimeout = 2 * time.Second
+	// A runner that has not polled for this long is considered stuck.
+	workerStaleAfter = 30 * time.Second
+)
+
+// readinessCheck is served unauthenticated, so Error only ever carries
+// fixed messages; the underlying errors are logged instead.
+type readinessCheck struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Current   *int64 `json:"current_version,omitempty"`
+	Expected  *int64 `json:"expected_version,omitempty"`
+	LastPoll  string `json:"last_poll,omitempty"`
+}
+
+// readyzHandler reports whether this instance can serve traffic: the
+// database answers, its schema is at the version this binary was built
+// with, and the background job runner is polling.
+func (cfg *apiConfig) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Status string                    `json:"status"`
+		Checks map[string]readinessCheck `json:"checks"`
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]readinessCheck{
+		"database":   cfg.checkDatabase(ctx),
+		"migrations": cfg.checkMigrations(ctx),
+		"jobs":       cfg.checkJobs(),
+	}
+	status, code := "ok", http.StatusOK
+	for _, c := range checks {
+		if c.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, response{Status: status, Checks: checks})
+}
+
+func (cfg *apiConfig) checkDatabase(ctx context.Context) readinessCheck {
+	start := time.Now()
+	if err := cfg.db.PingContext(ctx); err != nil {
+		loggerFrom(ctx).Warn("Readiness check failed", "check", "database", "error", err)
+		return readinessCheck{Status: "failed"}
+	}
+	return readinessCheck{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+}
+
+func (cfg *apiConfig) checkMigrations(ctx context.Context) readinessCheck {
+	expected, err := schema.LatestVersion()
+	if err != nil {
+		loggerFrom(ctx).Warn("Readiness check failed", "check", "migrations", "error", err)
+		return readinessCheck{Status: "failed"}
+	}
+	var current int64
+	err = cfg.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied",
+	).Scan(&current)
+	if err != nil {
+		loggerFrom(ctx).Warn("Readiness check failed", "check", "migrations", "error", err)
+		return readinessCheck{Status: "failed", Expected: &expected}
+	}
+	check := readinessCheck{Status: "ok", Current: &current, Expected: &expected}
+	if current != expected {
+		check.Status = "failed"
+		check.Error = fmt.Sprintf("database schema is at version %d, expected %d", current, expected)
+	}
+	return check
+}
+
+func (cfg *apiConfig) checkJobs() readinessCheck {
+	if cfg.jobs == nil {
+		return readinessCheck{Status: "failed", Error: "job runner not configured"}
+	}
+	status := cfg.jobs.Status()
+	// The runner logs its own polling errors; they are not repeated here.
+	check := readinessCheck{Status: "ok"}
+	if !status.LastPoll.IsZero() {
+		check.LastPoll = status.LastPoll.UTC().Format(time.RFC3339)
+	}
+	switch {
+	case !status.Running:
+		check.Status = "failed"
+		check.Error = "job runner is not running"
+	case time.Since(status.LastPoll) > workerStaleAfter:
+		check.Status = "failed"
+		check.Error = "job runner has not polled recently"
+	}
+	return check
+}
@@ -12,12 +12,22 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+// Claims are the JWT claims Chirpy issues. Tokens without a role claim
+// predate roles and are treated as RoleUser.
+type Claims struct {
+	Role Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func MakeJWT(userID uuid.UUID, role Role, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
 	})
 	jwtToken, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
@@ -27,7 +37,13 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	var claims jwt.RegisteredClaims
+	userID, _, err := ValidateJWTRole(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ValidateJWTRole is ValidateJWT that also returns the role claim.
+func ValidateJWTRole(tokenString, tokenSecret string) (uuid.UUID, Role, error) {
+	var claims Claims
 
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		//Ensure the token uses the expected signing method
@@ -37,16 +53,19 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("error parsing token: %w", err)
+		return uuid.UUID{}, "", fmt.Errorf("error parsing token: %w", err)
 	}
 	if !token.Valid {
-		return uuid.UUID{}, fmt.Errorf("invalid token")
+		return uuid.UUID{}, "", fmt.Errorf("invalid token")
 	}
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("error parsing user ID: %w", err)
+		return uuid.UUID{}, "", fmt.Errorf("error parsing user ID: %w", err)
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
 	}
-	return userID, nil
+	return userID, claims.Role, nil
 
 }
 
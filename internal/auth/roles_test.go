@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	testCases := []struct {
+		role, min Role
+		want      bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{Role("root"), RoleUser, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.role.AtLeast(tc.min); got != tc.want {
+			t.Errorf("%q.AtLeast(%q) = %v, want %v", tc.role, tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestJWTRoleClaim(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeJWT(userID, RoleModerator, "secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotID, role, err := ValidateJWTRole(token, "secret")
+	if err != nil || gotID != userID || role != RoleModerator {
+		t.Errorf("ValidateJWTRole = %v, %q, %v", gotID, role, err)
+	}
+
+	// Tokens issued before roles existed carry no role claim.
+	old, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, role, err := ValidateJWTRole(old, "secret"); err != nil || role != RoleUser {
+		t.Errorf("ValidateJWTRole(old token) role = %q, %v; want user", role, err)
+	}
+}
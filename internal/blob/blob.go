@@ -0,0 +1,42 @@
+// Package blob stores uploaded files under opaque keys, either on the
+// local filesystem or in an S3-compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get returns the blob's content and content type. The caller must
+	// close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	// Delete removes a blob. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape a directory or bucket prefix.
+// Keys are slash-separated paths of letters, digits, '-', '_' and '.'.
+func checkKey(key string) error {
+	if key == "" || len(key) > 512 {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return fmt.Errorf("invalid blob key %q", key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Package chirptext normalizes chirp bodies and measures them the way a
+// reader would count them.
+//
+// A chirp's length is the number of user-perceived characters (extended
+// grapheme clusters) in its normalized form, so "é", "👍🏽", "🇯🇵" and
+// "👩‍👩‍👧" each count as one. Every http:// or https:// URL counts as
+// URLLength characters regardless of how long it is, so shortened and full
+// links cost the same.
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxLength is the most characters a chirp may contain.
+	MaxLength = 140
+	// URLLength is what each URL counts toward MaxLength.
+	URLLength = 23
+)
+
+var urlPattern = regexp.MustCompile(`https?://\S+`)
+
+// Normalize returns s in NFC with surrounding whitespace trimmed, control
+// characters other than newline and tab removed, and invisible formatting
+// characters removed. The zero-width joiner and non-joiner and the tag
+// characters are kept because emoji sequences and several scripts depend
+// on them.
+func Normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if keep(r) {
+			return r
+		}
+		return -1
+	}, s)
+	return strings.TrimSpace(norm.NFC.String(s))
+}
+
+func keep(r rune) bool {
+	switch {
+	case r == '\n' || r == '\t':
+		return true
+	case r == '\u200c' || r == '\u200d':
+		return true
+	case r >= '\U000e0020' && r <= '\U000e007f':
+		return true
+	}
+	return !unicode.Is(unicode.Cc, r) && !unicode.Is(unicode.Cf, r)
+}
+
+// Length reports how many characters s counts for. s should already be
+// normalized.
+func Length(s string) int {
+	n := 0
+	for _, loc := range urlPattern.FindAllStringIndex(s, -1) {
+		n += URLLength - uniseg.GraphemeClusterCount(s[loc[0]:loc[1]])
+	}
+	return n + uniseg.GraphemeClusterCount(s)
+}
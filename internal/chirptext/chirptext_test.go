@@ -0,0 +1,87 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"decomposed accent composed", "cafe\u0301", "caf\u00e9"},
+		{"hangul jamo composed", "\u1100\u1161", "\uac00"},
+		{"zero width space removed", "hel\u200blo", "hello"},
+		{"byte order mark removed", "\ufeffhello", "hello"},
+		{"word joiner removed", "a\u2060b", "ab"},
+		{"bidi override removed", "abc\u202eexe.txt", "abcexe.txt"},
+		{"control characters removed", "a\x00b\x07c\x1b[31m", "abc[31m"},
+		{"newline and tab kept", "a\nb\tc", "a\nb\tc"},
+		{"surrounding space trimmed", "  hi \n", "hi"},
+		{"zero width joiner kept", "\U0001f469\u200d\U0001f4bb", "\U0001f469\u200d\U0001f4bb"},
+		{"zero width non-joiner kept", "\u0645\u06cc\u200c\u062e\u0648\u0627\u0647\u0645", "\u0645\u06cc\u200c\u062e\u0648\u0627\u0647\u0645"},
+		{"subdivision flag kept", "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%+q) = %+q, want %+q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// corpus pairs text in a range of scripts with the length a reader would
+// count for it.
+var corpus = []struct {
+	name string
+	text string
+	want int
+}{
+	{"ascii", "Hello, world!", 13},
+	{"latin precomposed", "Ça va très bien", 15},
+	{"latin combining marks", "Cafe\u0301 cre\u0300me", 10},
+	{"greek", "Καλημέρα", 8},
+	{"cyrillic", "Привет, мир", 11},
+	{"hebrew with points", "\u05e9\u05c1\u05b8\u05dc\u05d5\u05b9\u05dd", 4},
+	{"arabic", "مرحبا بالعالم", 13},
+	// Conjuncts split at the virama until Unicode 15.1 rule GB9c: न म स् ते.
+	{"devanagari", "\u0928\u092e\u0938\u094d\u0924\u0947", 4},
+	{"tamil", "\u0ba4\u0bae\u0bbf\u0bb4\u0bcd", 3},
+	{"thai", "\u0e2a\u0e27\u0e31\u0e2a\u0e14\u0e35", 4},
+	{"chinese", "你好，世界", 5},
+	{"japanese", "こんにちは世界", 7},
+	{"korean", "안녕하세요", 5},
+	{"emoji", "\U0001f600\U0001f389", 2},
+	{"emoji skin tone", "\U0001f44d\U0001f3fd", 1},
+	{"emoji zwj family", "\U0001f469\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466", 1},
+	{"regional indicator flags", "\U0001f1ef\U0001f1f5\U0001f1e7\U0001f1f7", 2},
+	{"subdivision flag", "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", 1},
+	{"keycap", "1\ufe0f\u20e3", 1},
+	{"zalgo", "Z\u0351\u036b\u0343\u036aa\u0310\u0346l", 3},
+	{"url", "read https://example.com/a/very/long/path?with=query&and=more", 5 + URLLength},
+	{"two urls", "http://a.io and https://b.io/x", 5 + 2*URLLength},
+	{"scheme alone is text", "https:// nope", 13},
+}
+
+func TestLength(t *testing.T) {
+	for _, tt := range corpus {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Normalize(tt.text)
+			if got := Length(s); got != tt.want {
+				t.Errorf("Length(%+q) = %d, want %d", s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLengthAtLimit(t *testing.T) {
+	for _, unit := range []string{"a", "e\u0301", "你", "\U0001f44d\U0001f3fd", "\U0001f1ef\U0001f1f5"} {
+		s := strings.Repeat(unit, MaxLength)
+		if got := Length(Normalize(s)); got != MaxLength {
+			t.Errorf("%d x %+q counts %d, want %d", MaxLength, unit, got, MaxLength)
+		}
+	}
+}
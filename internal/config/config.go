@@ -0,0 +1,468 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every runtime setting. Values are applied in order of
+// increasing precedence: defaults, the YAML config file, environment
+// variables (or NAME_FILE for fields tagged secret), then flags.
+//
+// Supported field types are string, int, float64, bool and time.Duration.
+type Config struct {
+	Platform    string `yaml:"platform" env:"PLATFORM" flag:"platform" usage:"deployment platform (dev enables admin reset)"`
+	DatabaseURL string `yaml:"db_url" env:"DB_URL" secret:"true"`
+	JWTSecret   string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	PolkaKey    string `yaml:"polka_key" env:"POLKA_KEY" secret:"true"`
+	AdminAPIKey string `yaml:"admin_api_key" env:"ADMIN_API_KEY" secret:"true"`
+
+	JWTLifetime          time.Duration `yaml:"jwt_lifetime" env:"JWT_LIFETIME" flag:"jwt-lifetime" usage:"lifetime of access tokens"`
+	RefreshTokenLifetime time.Duration `yaml:"refresh_token_lifetime" env:"REFRESH_TOKEN_LIFETIME" flag:"refresh-token-lifetime" usage:"lifetime of refresh tokens"`
+
+	LogLevel  string `yaml:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum log level: debug, info, warn or error"`
+	LogFormat string `yaml:"log_format" env:"LOG_FORMAT" flag:"log-format" usage:"log output format: text or json"`
+
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START" flag:"migrate-on-start" usage:"apply pending database migrations before serving"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Login     LoginConfig     `yaml:"login"`
+	Mail      MailConfig      `yaml:"mail"`
+	Media     MediaConfig     `yaml:"media"`
+
+	// PrintConfig is only settable by flag and is never written out.
+	PrintConfig bool `yaml:"-"`
+	// Args holds the arguments left after flags, e.g. the migrate command.
+	Args []string `yaml:"-"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" env:"ADDR" flag:"addr" usage:"listen address"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" flag:"read-timeout" usage:"maximum duration for reading a request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" flag:"read-header-timeout" usage:"maximum duration for reading request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" flag:"write-timeout" usage:"maximum duration before timing out a response write"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" flag:"idle-timeout" usage:"keep-alive idle timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long to drain requests and jobs on shutdown"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" flag:"max-header-bytes" usage:"maximum size of request headers"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"trace exporter: none, otlp, stdout or file"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_OTLP_ENDPOINT" flag:"tracing-otlp-endpoint" usage:"OTLP/HTTP collector host:port (defaults to OTEL_EXPORTER_OTLP_ENDPOINT)"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_OTLP_INSECURE" flag:"tracing-otlp-insecure" usage:"send OTLP over plain HTTP"`
+	File        string  `yaml:"file" env:"TRACING_FILE" flag:"tracing-file" usage:"destination for the file exporter"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"fraction of new traces to sample, 0 to 1"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool   `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit" usage:"enable request rate limiting"`
+	Store   string `yaml:"store" env:"RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"rate limit bucket store: memory or postgres"`
+	// TrustProxy keys anonymous clients by the last X-Forwarded-For
+	// address, the one appended by the proxy in front of chirpy, instead
+	// of the connection address.
+	TrustProxy bool `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY" flag:"rate-limit-trust-proxy" usage:"use X-Forwarded-For to identify clients"`
+
+	// Default applies to routes without an entry in Routes, which is
+	// keyed by mux pattern, e.g. "POST /api/login".
+	Default RouteLimit            `yaml:"default"`
+	Routes  map[string]RouteLimit `yaml:"routes"`
+}
+
+// RouteLimit allows Requests per Per for each client. Authenticated Chirpy
+// Red users get RedRequests instead when it is set.
+type RouteLimit struct {
+	Requests    int           `yaml:"requests"`
+	RedRequests int           `yaml:"red_requests,omitempty"`
+	Per         time.Duration `yaml:"per"`
+}
+
+// LoginConfig throttles password guessing. Failures are counted per email
+// and per client IP inside Window.
+type LoginConfig struct {
+	DelayAfter      int           `yaml:"delay_after" env:"LOGIN_DELAY_AFTER" flag:"login-delay-after" usage:"failures per email before progressive delays start (0 disables)"`
+	MaxDelay        time.Duration `yaml:"max_delay" env:"LOGIN_MAX_DELAY" flag:"login-max-delay" usage:"longest progressive delay between login attempts"`
+	LockoutAfter    int           `yaml:"lockout_after" env:"LOGIN_LOCKOUT_AFTER" flag:"login-lockout-after" usage:"failures per email before the account is locked (0 disables)"`
+	IPLockoutAfter  int           `yaml:"ip_lockout_after" env:"LOGIN_IP_LOCKOUT_AFTER" flag:"login-ip-lockout-after" usage:"failures per client IP before it is locked out (0 disables)"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION" flag:"login-lockout-duration" usage:"how long a lockout lasts"`
+	Window          time.Duration `yaml:"window" env:"LOGIN_WINDOW" flag:"login-window" usage:"period over which failures are counted"`
+}
+
+type MailConfig struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" flag:"mail-driver" usage:"mail delivery: log or smtp"`
+	From         string `yaml:"from" env:"MAIL_FROM" flag:"mail-from" usage:"sender address"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST" flag:"smtp-host" usage:"SMTP relay host"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" flag:"smtp-port" usage:"SMTP relay port"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME" flag:"smtp-username" usage:"SMTP username"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// MediaConfig controls image uploads and where they are stored.
+type MediaConfig struct {
+	Store         string `yaml:"store" env:"MEDIA_STORE" flag:"media-store" usage:"blob store for uploads: local or s3"`
+	Dir           string `yaml:"dir" env:"MEDIA_DIR" flag:"media-dir" usage:"directory for the local blob store"`
+	MaxBytes      int    `yaml:"max_bytes" env:"MEDIA_MAX_BYTES" flag:"media-max-bytes" usage:"largest accepted upload in bytes"`
+	MaxPixels     int    `yaml:"max_pixels" env:"MEDIA_MAX_PIXELS" flag:"media-max-pixels" usage:"largest accepted image area in pixels"`
+	ThumbnailSize int    `yaml:"thumbnail_size" env:"MEDIA_THUMBNAIL_SIZE" flag:"media-thumbnail-size" usage:"longest side of generated thumbnails in pixels"`
+
+	S3Endpoint  string `yaml:"s3_endpoint" env:"S3_ENDPOINT" flag:"s3-endpoint" usage:"S3-compatible service URL"`
+	S3Region    string `yaml:"s3_region" env:"S3_REGION" flag:"s3-region" usage:"S3 region"`
+	S3Bucket    string `yaml:"s3_bucket" env:"S3_BUCKET" flag:"s3-bucket" usage:"S3 bucket for uploads"`
+	S3AccessKey string `yaml:"s3_access_key" env:"S3_ACCESS_KEY" flag:"s3-access-key" usage:"S3 access key ID"`
+	S3SecretKey string `yaml:"s3_secret_key" env:"S3_SECRET_KEY" secret:"true"`
+	S3PathStyle bool   `yaml:"s3_path_style" env:"S3_PATH_STYLE" flag:"s3-path-style" usage:"use path-style S3 URLs, as most self-hosted services require"`
+}
+
+func Default() Config {
+	return Config{
+		JWTLifetime:          time.Hour,
+		RefreshTokenLifetime: 60 * 24 * time.Hour,
+		LogLevel:             "info",
+		LogFormat:            "text",
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Store:   "memory",
+			Default: RouteLimit{Requests: 300, RedRequests: 1200, Per: time.Minute},
+			Routes: map[string]RouteLimit{
+				"POST /api/login":   {Requests: 10, Per: time.Minute},
+				"POST /api/users":   {Requests: 5, Per: time.Minute},
+				"POST /api/refresh": {Requests: 30, Per: time.Minute},
+				"POST /api/chirps":  {Requests: 30, RedRequests: 150, Per: time.Minute},
+				"POST /api/media":   {Requests: 10, RedRequests: 50, Per: time.Minute},
+				// Publishing a draft creates a chirp, so it gets the same limit.
+				"POST /api/me/drafts/{draftID}/publish": {Requests: 30, RedRequests: 150, Per: time.Minute},
+			},
+		},
+		Login: LoginConfig{
+			DelayAfter:      3,
+			MaxDelay:        30 * time.Second,
+			LockoutAfter:    10,
+			IPLockoutAfter:  100,
+			LockoutDuration: 15 * time.Minute,
+			Window:          15 * time.Minute,
+		},
+		Mail: MailConfig{
+			Driver:   "log",
+			From:     "Chirpy <no-reply@chirpy.local>",
+			SMTPPort: 587,
+		},
+		Media: MediaConfig{
+			Store:         "local",
+			Dir:           "uploads",
+			MaxBytes:      5 << 20,
+			MaxPixels:     25_000_000,
+			ThumbnailSize: 320,
+			S3Region:      "us-east-1",
+		},
+	}
+}
+
+// Load builds a Config from args (without the program name), the process
+// environment and the config file named by -config or CHIRPY_CONFIG.
+func Load(args []string) (Config, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	fields := collectFields(reflect.ValueOf(&cfg).Elem())
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML config file (env CHIRPY_CONFIG)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, f := range fields {
+		if f.flag != "" {
+			fs.Var(&rawFlag{isBool: f.value.Kind() == reflect.Bool}, f.flag, f.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv("CHIRPY_CONFIG")
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("error reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("error parsing config file %s: %w", path, err)
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if f.secret {
+			if file, ok := lookupEnv(f.env + "_FILE"); ok && file != "" {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return Config{}, fmt.Errorf("error reading %s_FILE: %w", f.env, err)
+				}
+				if err := f.set(strings.TrimSpace(string(data))); err != nil {
+					return Config{}, fmt.Errorf("invalid %s_FILE: %w", f.env, err)
+				}
+				continue
+			}
+		}
+		if val, ok := lookupEnv(f.env); ok && val != "" {
+			if err := f.set(val); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name && flagErr == nil {
+				if err := f.set(fl.Value.String()); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", f.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	cfg.PrintConfig = *printConfig
+	cfg.Args = fs.Args()
+	return cfg, nil
+}
+
+// Validate reports every missing or out-of-range setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Platform == "" {
+		errs = append(errs, errors.New("PLATFORM must be set"))
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DB_URL must be set"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("JWT_SECRET must be set"))
+	}
+	if c.PolkaKey == "" {
+		errs = append(errs, errors.New("POLKA_KEY must be set"))
+	}
+	if c.JWTLifetime <= 0 {
+		errs = append(errs, errors.New("jwt_lifetime must be positive"))
+	}
+	if c.RefreshTokenLifetime <= 0 {
+		errs = append(errs, errors.New("refresh_token_lifetime must be positive"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log_level %q", c.LogLevel))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format must be text or json, got %q", c.LogFormat))
+	}
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must be set"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("server.max_header_bytes must be positive"))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file must be set for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, otlp, stdout or file, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		errs = append(errs, fmt.Errorf("rate_limit.store must be memory or postgres, got %q", c.RateLimit.Store))
+	}
+	if err := c.RateLimit.Default.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.default: %w", err))
+	}
+	for route, limit := range c.RateLimit.Routes {
+		if err := limit.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.routes[%q]: %w", route, err))
+		}
+	}
+	if c.Login.DelayAfter < 0 || c.Login.LockoutAfter < 0 || c.Login.IPLockoutAfter < 0 {
+		errs = append(errs, errors.New("login thresholds must not be negative"))
+	}
+	if c.Login.Window <= 0 || c.Login.LockoutDuration <= 0 || c.Login.MaxDelay <= 0 {
+		errs = append(errs, errors.New("login.window, login.lockout_duration and login.max_delay must be positive"))
+	}
+	switch c.Mail.Driver {
+	case "log":
+	case "smtp":
+		if c.Mail.SMTPHost == "" || c.Mail.From == "" {
+			errs = append(errs, errors.New("mail.smtp_host and mail.from must be set for the smtp driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver must be log or smtp, got %q", c.Mail.Driver))
+	}
+	switch c.Media.Store {
+	case "local":
+		if c.Media.Dir == "" {
+			errs = append(errs, errors.New("media.dir must be set for the local store"))
+		}
+	case "s3":
+		if c.Media.S3Endpoint == "" || c.Media.S3Bucket == "" || c.Media.S3AccessKey == "" || c.Media.S3SecretKey == "" {
+			errs = append(errs, errors.New("media.s3_endpoint, media.s3_bucket, media.s3_access_key and S3_SECRET_KEY must be set for the s3 store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("media.store must be local or s3, got %q", c.Media.Store))
+	}
+	if c.Media.MaxBytes <= 0 || c.Media.MaxPixels <= 0 || c.Media.ThumbnailSize <= 0 {
+		errs = append(errs, errors.New("media.max_bytes, media.max_pixels and media.thumbnail_size must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (l RouteLimit) validate() error {
+	if l.Requests <= 0 || l.Per <= 0 {
+		return errors.New("requests and per must be positive")
+	}
+	if l.RedRequests < 0 {
+		return errors.New("red_requests must not be negative")
+	}
+	return nil
+}
+
+// Logger builds the process logger described by LogLevel and LogFormat.
+// Call it only on a validated Config.
+func (c Config) Logger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Print writes the configuration as YAML with secret fields redacted.
+func (c Config) Print(w io.Writer) error {
+	redacted := c
+	for _, f := range collectFields(reflect.ValueOf(&redacted).Elem()) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString("[REDACTED]")
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(redacted); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type field struct {
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func collectFields(v reflect.Value) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(fv)...)
+			continue
+		}
+		if sf.Tag.Get("env") == "" && sf.Tag.Get("flag") == "" {
+			continue
+		}
+		fields = append(fields, field{
+			env:    sf.Tag.Get("env"),
+			flag:   sf.Tag.Get("flag"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+	return fields
+}
+
+// rawFlag keeps the flag text for field.set. Bool fields accept a bare
+// -name like flag.Bool does.
+type rawFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *rawFlag) String() string     { return f.value }
+func (f *rawFlag) Set(s string) error { f.value = s; return nil }
+func (f *rawFlag) IsBoolFlag() bool   { return f.isBool }
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case string:
+		f.value.SetString(s)
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(n)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config type %s", f.value.Type())
+	}
+	return nil
+}
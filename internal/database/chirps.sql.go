@@ -7,29 +7,44 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body          string
+	UserID        uuid.UUID
+	Status        string
+	PublishAt     sql.NullTime
+	QuotedChirpID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+		arg.QuotedChirpID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +52,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.QuotedChirpID,
 	)
 	return i, err
 }
@@ -51,8 +69,78 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirps = `-- name: DeleteChirps :execrows
+DELETE FROM chirps
+`
+
+func (q *Queries) DeleteChirps(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirps)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status = 'scheduled'
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE id = $1 AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::UUID)
+    OR (blocks.blocker_id = $2::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::UUID AND mutes.muted_id = chirps.user_id
+)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.QuotedChirpID,
+	)
+	return i, err
+}
+
 const grabChirp = `-- name: GrabChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
 WHERE id = $1
 `
 
@@ -65,17 +153,181 @@ func (q *Queries) GrabChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.QuotedChirpID,
 	)
 	return i, err
 }
 
+const listChirpsByUser = `-- name: ListChirpsByUser :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE user_id = $1 AND status = 'scheduled'
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVisibleChirps = `-- name: ListVisibleChirps :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE id = ANY($1::UUID[]) AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::UUID)
+    OR (blocks.blocker_id = $2::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::UUID AND mutes.muted_id = chirps.user_id
+)
+`
+
+type ListVisibleChirpsParams struct {
+	Ids      []uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) ListVisibleChirps(ctx context.Context, arg ListVisibleChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listVisibleChirps, pq.Array(arg.Ids), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishScheduledChirp = `-- name: PublishScheduledChirp :execrows
+UPDATE chirps
+SET status = 'published', created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled' AND publish_at <= NOW()
+`
+
+func (q *Queries) PublishScheduledChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, publishScheduledChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const retrieveChirps = `-- name: RetrieveChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::UUID)
+    OR (blocks.blocker_id = $1::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1::UUID AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) RetrieveChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, retrieveChirps)
+func (q *Queries) RetrieveChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, retrieveChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +341,9 @@ func (q *Queries) RetrieveChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.QuotedChirpID,
 		); err != nil {
 			return nil, err
 		}
@@ -104,13 +359,32 @@ func (q *Queries) RetrieveChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const retrieveChirpsByAuthor = `-- name: RetrieveChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE user_id = $1 AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::UUID)
+    OR (blocks.blocker_id = $2::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::UUID AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) RetrieveChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, retrieveChirpsByAuthor, userID)
+type RetrieveChirpsByAuthorParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) RetrieveChirpsByAuthor(ctx context.Context, arg RetrieveChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, retrieveChirpsByAuthor, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +398,9 @@ func (q *Queries) RetrieveChirpsByAuthor(ctx context.Context, userID uuid.UUID)
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.QuotedChirpID,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +414,82 @@ func (q *Queries) RetrieveChirpsByAuthor(ctx context.Context, userID uuid.UUID)
 	}
 	return items, nil
 }
+
+const seedChirp = `-- name: SeedChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES ($1, $2, $2, $3, $4)
+ON CONFLICT DO NOTHING
+`
+
+type SeedChirpParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) SeedChirp(ctx context.Context, arg SeedChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, seedChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.Body,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setChirpStatus = `-- name: SetChirpStatus :exec
+UPDATE chirps
+SET status = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetChirpStatusParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) SetChirpStatus(ctx context.Context, arg SetChirpStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpStatus, arg.ID, arg.Status)
+	return err
+}
+
+const updateScheduledChirp = `-- name: UpdateScheduledChirp :one
+UPDATE chirps
+SET body = $1, status = $2, publish_at = $3, updated_at = NOW()
+WHERE id = $4 AND user_id = $5 AND status = 'scheduled'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id
+`
+
+type UpdateScheduledChirpParams struct {
+	Body      string
+	Status    string
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) UpdateScheduledChirp(ctx context.Context, arg UpdateScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledChirp,
+		arg.Body,
+		arg.Status,
+		arg.PublishAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.QuotedChirpID,
+	)
+	return i, err
+}
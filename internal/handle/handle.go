@@ -0,0 +1,60 @@
+// Package handle validates the public @handles users are known by.
+//
+// A handle is 3 to 15 ASCII letters, digits and underscores and is unique
+// regardless of case. Handles naming the service or its staff, and paths
+// the API or web app might claim, are reserved.
+package handle
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 15
+)
+
+var (
+	ErrLength     = errors.New("must be 3 to 15 characters")
+	ErrCharacters = errors.New("may only contain letters, digits and underscores")
+	ErrReserved   = errors.New("is reserved")
+)
+
+var reserved = map[string]bool{
+	"about": true, "account": true, "api": true, "app": true, "blocks": true,
+	"help": true, "home": true, "login": true, "logout": true, "me": true,
+	"mod": true, "moderator": true, "mutes": true, "null": true, "root": true,
+	"search": true, "settings": true, "signup": true, "staff": true,
+	"support": true, "system": true, "undefined": true, "users": true,
+}
+
+// reservedParts may not appear anywhere in a handle.
+var reservedParts = []string{"admin", "chirpy"}
+
+// Trim removes a leading @, which users often type.
+func Trim(h string) string {
+	return strings.TrimPrefix(strings.TrimSpace(h), "@")
+}
+
+// Validate reports why h cannot be used as a handle, or nil if it can.
+func Validate(h string) error {
+	if len(h) < MinLength || len(h) > MaxLength {
+		return ErrLength
+	}
+	for _, r := range h {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return ErrCharacters
+		}
+	}
+	lower := strings.ToLower(h)
+	if reserved[lower] {
+		return ErrReserved
+	}
+	for _, part := range reservedParts {
+		if strings.Contains(lower, part) {
+			return ErrReserved
+		}
+	}
+	return nil
+}
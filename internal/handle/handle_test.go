@@ -0,0 +1,37 @@
+package handle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   error
+	}{
+		{"bob", nil},
+		{"Alice_1984", nil},
+		{"abcdefghijklmno", nil},
+		{"ab", ErrLength},
+		{"abcdefghijklmnop", ErrLength},
+		{"bob smith", ErrCharacters},
+		{"bob-smith", ErrCharacters},
+		{"bøb", ErrCharacters},
+		{"Support", ErrReserved},
+		{"me", ErrLength},
+		{"the_admin", ErrReserved},
+		{"ChirpyFan", ErrReserved},
+	}
+	for _, tt := range tests {
+		if got := Validate(tt.handle); !errors.Is(got, tt.want) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.handle, got, tt.want)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	if got := Trim(" @bob "); got != "bob" {
+		t.Errorf("Trim = %q, want bob", got)
+	}
+}
@@ -0,0 +1,317 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler processes a single claimed job. Returning an error schedules a
+// retry unless the error is wrapped with Permanent or the job has used up
+// its attempts, in which case the job is dead-lettered.
+type Handler func(ctx context.Context, job database.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type EnqueueOptions struct {
+	RunAt       time.Time
+	MaxAttempts int32
+	DedupeKey   string
+}
+
+// ErrDuplicate is returned by Enqueue when a pending or running job already
+// holds the requested dedupe key.
+var ErrDuplicate = errors.New("job with the same dedupe key is already queued")
+
+// Enqueue inserts a job using q. Pass a transaction-scoped Queries (see
+// database.Queries.WithTx) to enqueue atomically with other writes.
+func Enqueue(ctx context.Context, q *database.Queries, kind string, payload any, opts EnqueueOptions) (database.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return database.Job{}, fmt.Errorf("error encoding job payload: %w", err)
+	}
+	// run_at is a TIMESTAMP compared with NOW(), so it is stored in UTC.
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+	opts.RunAt = opts.RunAt.UTC()
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 5
+	}
+	job, err := q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     data,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+		DedupeKey: sql.NullString{
+			String: opts.DedupeKey,
+			Valid:  opts.DedupeKey != "",
+		},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Job{}, ErrDuplicate
+	}
+	if err != nil {
+		return database.Job{}, fmt.Errorf("error enqueueing job: %w", err)
+	}
+	return job, nil
+}
+
+// Runner polls the jobs table and dispatches claimed jobs to registered
+// handlers. Jobs are claimed with FOR UPDATE SKIP LOCKED so several
+// runners can share a table.
+type Runner struct {
+	queries      *database.Queries
+	logger       *slog.Logger
+	handlers     map[string]Handler
+	pollInterval time.Duration
+	lockTimeout  time.Duration
+	concurrency  int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Status describes the runner for health checks.
+type Status struct {
+	Running bool
+	// LastPoll is when jobs were last claimed successfully (even if there
+	// were none); LastError is the most recent polling error since then.
+	LastPoll  time.Time
+	LastError string
+}
+
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *Runner) setStatus(update func(*Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.status)
+}
+
+func NewRunner(queries *database.Queries, logger *slog.Logger) *Runner {
+	return &Runner{
+		queries:      queries,
+		logger:       logger,
+		handlers:     map[string]Handler{},
+		pollInterval: time.Second,
+		lockTimeout:  5 * time.Minute,
+		concurrency:  4,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Register must be called before Start.
+func (r *Runner) Register(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+func (r *Runner) Start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// Shutdown stops claiming new jobs and waits for in-flight jobs to finish
+// or for ctx to expire. Jobs still running when ctx expires are picked up
+// again once their lock goes stale.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) loop() {
+	defer r.wg.Done()
+	r.setStatus(func(s *Status) { s.Running = true })
+	defer r.setStatus(func(s *Status) { s.Running = false })
+
+	// In-flight jobs get a context that is only cancelled when the runner
+	// is told to stop, so a slow handler can observe shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	sem := make(chan struct{}, r.concurrency)
+
+	for {
+		r.requeueStale(ctx)
+		for r.poll(ctx, sem) {
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll claims as many jobs as there are free workers and reports whether
+// it found a full batch, in which case the caller polls again immediately.
+func (r *Runner) poll(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case <-r.stop:
+		return false
+	default:
+	}
+
+	free := cap(sem) - len(sem)
+	if free == 0 {
+		// All workers are busy; the runner is healthy, just saturated.
+		r.setStatus(func(s *Status) { s.LastPoll = time.Now() })
+		return false
+	}
+	claimed, err := r.queries.ClaimJobs(ctx, int32(free))
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("Error claiming jobs", "error", err)
+			r.setStatus(func(s *Status) { s.LastError = err.Error() })
+		}
+		return false
+	}
+	r.setStatus(func(s *Status) {
+		s.LastPoll = time.Now()
+		s.LastError = ""
+	})
+	for _, job := range claimed {
+		sem <- struct{}{}
+		r.wg.Add(1)
+		go func(job database.Job) {
+			defer r.wg.Done()
+			defer func() { <-sem }()
+			r.run(ctx, job)
+		}(job)
+	}
+	return len(claimed) == free
+}
+
+func (r *Runner) run(ctx context.Context, job database.Job) {
+	ctx, span := tracing.Tracer().Start(ctx, "job "+job.Kind, trace.WithAttributes(
+		attribute.String("job.id", job.ID.String()),
+		attribute.Int("job.attempt", int(job.Attempts)),
+	))
+	defer span.End()
+
+	handler, ok := r.handlers[job.Kind]
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("no handler registered for job kind %q", job.Kind))
+	} else {
+		err = safeCall(ctx, handler, job)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	// Bookkeeping must succeed even if shutdown cancelled ctx.
+	bg := context.Background()
+	if err == nil {
+		if err := r.queries.CompleteJob(bg, job.ID); err != nil {
+			r.logger.Error("Error completing job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	var perm permanentError
+	if errors.As(err, &perm) || job.Attempts >= job.MaxAttempts {
+		r.logger.Warn("Dead-lettering job", "kind", job.Kind, "job_id", job.ID, "attempts", job.Attempts, "error", err)
+		if err := r.queries.DeadLetterJob(bg, database.DeadLetterJobParams{
+			LastError: lastError,
+			ID:        job.ID,
+		}); err != nil {
+			r.logger.Error("Error dead-lettering job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	if err := r.queries.RetryJob(bg, database.RetryJobParams{
+		RunAt:     time.Now().UTC().Add(Backoff(job.Attempts)),
+		LastError: lastError,
+		ID:        job.ID,
+	}); err != nil {
+		r.logger.Error("Error rescheduling job", "job_id", job.ID, "error", err)
+	}
+}
+
+func safeCall(ctx context.Context, h Handler, job database.Job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (r *Runner) requeueStale(ctx context.Context) {
+	stale, err := r.queries.RequeueStaleJobs(ctx, r.lockTimeout.Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("Error requeueing stale jobs", "error", err)
+		}
+		return
+	}
+	for _, job := range stale {
+		if job.Status == "dead" {
+			r.logger.Warn("Dead-lettering stale job", "kind", job.Kind, "job_id", job.ID, "attempts", job.Attempts)
+		} else {
+			r.logger.Info("Requeued stale job", "kind", job.Kind, "job_id", job.ID, "attempts", job.Attempts)
+		}
+	}
+}
+
+// Backoff returns how long to wait before the next attempt: 2^attempts
+// seconds, capped at one hour.
+func Backoff(attempts int32) time.Duration {
+	if attempts > 12 {
+		return time.Hour
+	}
+	d := time.Duration(1<<attempts) * time.Second
+	if d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
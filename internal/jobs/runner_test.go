@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		attempts int32
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{5, 32 * time.Second},
+		{12, time.Hour},
+		{40, time.Hour},
+	}
+	for _, tc := range testCases {
+		if got := Backoff(tc.attempts); got != tc.expected {
+			t.Errorf("Backoff(%d) = %v, expected %v", tc.attempts, got, tc.expected)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("bad payload")
+	err := fmt.Errorf("handler: %w", Permanent(base))
+
+	var perm permanentError
+	if !errors.As(err, &perm) {
+		t.Errorf("expected wrapped error to be permanent")
+	}
+	if !errors.Is(err, base) {
+		t.Errorf("expected permanent error to unwrap to the original error")
+	}
+}
@@ -0,0 +1,68 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/testdb"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{DelayAfter: 3, MaxDelay: 10 * time.Second}
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tc := range testCases {
+		if got := p.Delay(tc.failures); got != tc.expected {
+			t.Errorf("Delay(%d) = %v, expected %v", tc.failures, got, tc.expected)
+		}
+	}
+	if got := (Policy{}).Delay(50); got != 0 {
+		t.Errorf("zero policy should never delay, got %v", got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if EmailKey(" Walt@Breakingbad.com ") != EmailKey("walt@breakingbad.com") {
+		t.Errorf("email keys should ignore case and surrounding space")
+	}
+	if IPKey("10.0.0.1") == EmailKey("10.0.0.1") {
+		t.Errorf("ip and email keys must not collide")
+	}
+}
+
+func TestGuardOnNonUTCClock(t *testing.T) {
+	db := testdb.Open(t, "lockout_test")
+	ctx := context.Background()
+	policy := Policy{LockoutAfter: 2, LockoutDuration: 15 * time.Minute, Window: time.Hour}
+	g := New(database.New(db), policy, Policy{})
+	// Ten hours ahead of UTC: a stored local wall clock would read back
+	// as a lock ten hours longer than the policy allows.
+	zone := time.FixedZone("UTC+10", 10*60*60)
+	g.now = func() time.Time { return time.Now().In(zone) }
+
+	key := EmailKey("walt@breakingbad.com")
+	for i := 0; i < policy.LockoutAfter; i++ {
+		if _, err := g.Fail(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := g.Wait(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > policy.LockoutDuration {
+		t.Errorf("Wait() = %v, expected a lock of at most %v", wait, policy.LockoutDuration)
+	}
+}
@@ -0,0 +1,166 @@
+// Package media checks uploaded images and prepares them for storage.
+//
+// The content type is sniffed from the data rather than trusted from the
+// client. Images are decoded and re-encoded, which drops EXIF, XMP, text
+// chunks and anything else riding along with the pixels; the EXIF
+// orientation of JPEGs is applied first so photos keep their rotation.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrCorrupt         = errors.New("image could not be decoded")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+)
+
+// Limits bound the work done for one image.
+type Limits struct {
+	// MaxPixels bounds width × height, summed over frames for animated
+	// GIFs, so small files cannot decode into huge images.
+	MaxPixels int
+	// ThumbnailSize is the longest side of the generated thumbnail.
+	ThumbnailSize int
+}
+
+// Image is a processed upload ready to be stored.
+type Image struct {
+	ContentType   string
+	Data          []byte
+	Width         int
+	Height        int
+	ThumbnailType string
+	Thumbnail     []byte
+}
+
+var formats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Sniff returns the content type of data, or ErrUnsupportedType if it is
+// not a JPEG, PNG or GIF image.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := formats[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Process sniffs, decodes and re-encodes data and generates its thumbnail.
+func Process(data []byte, limits Limits) (*Image, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != formats[contentType] {
+		return nil, ErrCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > limits.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var first image.Image
+	var out bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		first = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&out, first, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		first = img
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+	case "image/gif":
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return nil, ErrCorrupt
+		}
+		if len(g.Image)*cfg.Width*cfg.Height > limits.MaxPixels {
+			return nil, ErrTooLarge
+		}
+		first = g.Image[0]
+		if err := gif.EncodeAll(&out, g); err != nil {
+			return nil, err
+		}
+	}
+
+	thumb := resize(first, limits.ThumbnailSize)
+	var thumbOut bytes.Buffer
+	thumbType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbType = "image/jpeg"
+		err = jpeg.Encode(&thumbOut, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&thumbOut, thumb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := first.Bounds()
+	return &Image{
+		ContentType:   contentType,
+		Data:          out.Bytes(),
+		Width:         b.Dx(),
+		Height:        b.Dy(),
+		ThumbnailType: thumbType,
+		Thumbnail:     thumbOut.Bytes(),
+	}, nil
+}
+
+// resize scales img down to fit in a size × size square by averaging the
+// source pixels behind each destination pixel. Smaller images are copied
+// unscaled.
+func resize(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,116 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var limits = Limits{MaxPixels: 4_000_000, ThumbnailSize: 320}
+
+// halves returns a w × h image whose left half is red and right half blue.
+func halves(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF inserts an APP1 segment holding only an Orientation tag right
+// after the JPEG's SOI marker.
+func withEXIF(data []byte, orientation byte) []byte {
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01" + "\x01\x12\x00\x03\x00\x00\x00\x01\x00" + string(orientation) + "\x00\x00" +
+		"\x00\x00\x00\x00")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestSniff(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, halves(2, 2))
+	if ct, err := Sniff(buf.Bytes()); err != nil || ct != "image/png" {
+		t.Errorf("Sniff(png) = %q, %v", ct, err)
+	}
+	for _, data := range [][]byte{[]byte("<html></html>"), []byte("%PDF-1.4"), {}} {
+		if _, err := Sniff(data); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Sniff(%q) = %v, want ErrUnsupportedType", data, err)
+		}
+	}
+}
+
+func TestProcessStripsEXIFAndOrients(t *testing.T) {
+	src := withEXIF(encodeJPEG(t, halves(40, 20)), 6)
+	if jpegOrientation(src) != 6 {
+		t.Fatalf("orientation = %d, want 6", jpegOrientation(src))
+	}
+	img, err := Process(src, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Error("EXIF segment survived processing")
+	}
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("size = %dx%d, want 20x40", img.Width, img.Height)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Turned clockwise, the red left half ends up on top.
+	if r, _, b, _ := decoded.At(10, 5).RGBA(); r < b {
+		t.Errorf("top of rotated image is not red")
+	}
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, halves(1000, 500))
+	img, err := Process(buf.Bytes(), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.ThumbnailType != "image/png" {
+		t.Errorf("types = %s, %s", img.ContentType, img.ThumbnailType)
+	}
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Errorf("thumbnail is %dx%d, want 320x160", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	if _, err := Process(encodeJPEG(t, halves(100, 100)), Limits{MaxPixels: 9_999, ThumbnailSize: 10}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized image: %v, want ErrTooLarge", err)
+	}
+	truncated := encodeJPEG(t, halves(100, 100))[:200]
+	if _, err := Process(truncated, limits); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated image: %v, want ErrCorrupt", err)
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerExposesRequestMetrics(t *testing.T) {
+	m := New(nil)
+	done := m.RequestStarted()
+	done(http.MethodGet, "GET /api/chirps/{chirpID}", http.StatusOK)
+	m.RequestStarted()(http.MethodGet, "", http.StatusNotFound)
+	m.LoginFailed()
+	m.ChirpsCreated.Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	expected := []string{
+		`chirpy_http_requests_total{method="GET",route="GET /api/chirps/{chirpID}",status="200"} 1`,
+		`chirpy_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`chirpy_http_requests_in_flight 0`,
+		`chirpy_logins_total{result="failed"} 1`,
+		`chirpy_chirps_created_total 1`,
+		`chirpy_http_request_duration_seconds_count{method="GET",route="GET /api/chirps/{chirpID}"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("metrics output missing %q", line)
+		}
+	}
+}
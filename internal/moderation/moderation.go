@@ -0,0 +1,323 @@
+// Package moderation screens chirp text against rules kept in the
+// moderation_rules table.
+//
+// Word rules match whole words after folding case, diacritics, leet-speak
+// and punctuation, so "Kerfuffle!", "K3rfuffl3" and "k.e.r.f.u.f.f.l.e" all
+// match "kerfuffle". Regex rules match the text as written, ignoring case.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Kind string
+
+const (
+	KindWord  Kind = "word"
+	KindRegex Kind = "regex"
+)
+
+// Action is what happens to a chirp that matches a rule.
+type Action string
+
+const (
+	// ActionMask replaces the matched text with asterisks.
+	ActionMask Action = "mask"
+	// ActionHold publishes nothing until a moderator reviews the chirp.
+	ActionHold Action = "hold"
+	// ActionReject refuses the chirp outright.
+	ActionReject Action = "reject"
+)
+
+var severity = map[Action]int{ActionMask: 1, ActionHold: 2, ActionReject: 3}
+
+const mask = "****"
+
+type Rule struct {
+	ID      uuid.UUID
+	Kind    Kind
+	Pattern string
+	Action  Action
+}
+
+// Check reports what is wrong with r, or nil if it can be compiled.
+func (r Rule) Check() error {
+	if _, ok := severity[r.Action]; !ok {
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	switch r.Kind {
+	case KindWord:
+		if Fold(r.Pattern) == "" {
+			return fmt.Errorf("word %q has no letters", r.Pattern)
+		}
+	case KindRegex:
+		if _, err := compileRegex(r.Pattern); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return nil
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("regex %q matches empty text", pattern)
+	}
+	return re, nil
+}
+
+type regexRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Engine applies a fixed set of rules. The zero Engine matches nothing.
+type Engine struct {
+	words   map[string]Rule
+	regexes []regexRule
+}
+
+// Compile builds an Engine from rules. When two word rules fold to the same
+// word the more severe action wins.
+func Compile(rules []Rule) (*Engine, error) {
+	e := &Engine{words: map[string]Rule{}}
+	for _, r := range rules {
+		if err := r.Check(); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if r.Kind == KindRegex {
+			re, _ := compileRegex(r.Pattern)
+			e.regexes = append(e.regexes, regexRule{Rule: r, re: re})
+			continue
+		}
+		word := Fold(r.Pattern)
+		if prev, ok := e.words[word]; !ok || severity[r.Action] > severity[prev.Action] {
+			e.words[word] = r
+		}
+	}
+	return e, nil
+}
+
+// Result is the outcome of screening a text.
+type Result struct {
+	// Text is the input with every mask rule applied.
+	Text string
+	// Action is the most severe action of any matching rule, or "" if
+	// nothing matched.
+	Action Action
+	// Matched lists the matching rules in the order they first matched.
+	Matched []Rule
+}
+
+func (res *Result) match(r Rule) {
+	for _, m := range res.Matched {
+		if m.ID == r.ID && m.Pattern == r.Pattern {
+			return
+		}
+	}
+	res.Matched = append(res.Matched, r)
+	if severity[r.Action] > severity[res.Action] {
+		res.Action = r.Action
+	}
+}
+
+// Apply screens text against every rule.
+func (e *Engine) Apply(text string) Result {
+	res := Result{}
+	if e == nil {
+		res.Text = text
+		return res
+	}
+	var b strings.Builder
+	for len(text) > 0 {
+		i := strings.IndexFunc(text, unicode.IsSpace)
+		if i < 0 {
+			i = len(text)
+		}
+		b.WriteString(e.applyWord(text[:i], &res))
+		text = text[i:]
+		j := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsSpace(r) })
+		if j < 0 {
+			j = len(text)
+		}
+		b.WriteString(text[:j])
+		text = text[j:]
+	}
+	res.Text = b.String()
+	for _, r := range e.regexes {
+		if !r.re.MatchString(res.Text) {
+			continue
+		}
+		res.match(r.Rule)
+		if r.Action == ActionMask {
+			res.Text = r.re.ReplaceAllLiteralString(res.Text, mask)
+		}
+	}
+	return res
+}
+
+// applyWord checks one whitespace-delimited token, keeping any punctuation
+// around the word itself.
+func (e *Engine) applyWord(token string, res *Result) string {
+	start := strings.IndexFunc(token, isWordRune)
+	if start < 0 {
+		return token
+	}
+	end := strings.LastIndexFunc(token, isWordRune)
+	_, size := utf8.DecodeRuneInString(token[end:])
+	end += size
+	r, ok := e.words[Fold(token[start:end])]
+	if !ok {
+		return token
+	}
+	res.match(r)
+	if r.Action != ActionMask {
+		return token
+	}
+	return token[:start] + mask + token[end:]
+}
+
+func isWordRune(r rune) bool {
+	_, leet := leet[r]
+	return leet || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'@': 'a', '$': 's',
+}
+
+// Fold reduces s to the lowercase letters a reader would see in it: accents
+// are stripped, leet-speak digits and symbols become letters and everything
+// else is dropped.
+func Fold(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+		if unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// Filter caches the Engine built from the moderation_rules table,
+// rebuilding it when it is older than the reload interval or has been
+// invalidated, so rule changes reach every instance without a restart.
+type Filter struct {
+	queries *database.Queries
+	reload  time.Duration
+	now     func() time.Time
+
+	// loading is held while the rules are fetched, so one caller reloads
+	// while the others keep using the current Engine.
+	loading sync.Mutex
+
+	mu         sync.Mutex
+	engine     *Engine
+	err        error
+	nextLoad   time.Time
+	generation uint64
+}
+
+// retryAfterFailure is how long a failed reload is remembered before the
+// database is asked again.
+const retryAfterFailure = 5 * time.Second
+
+func NewFilter(queries *database.Queries, reload time.Duration) *Filter {
+	return &Filter{queries: queries, reload: reload, now: time.Now}
+}
+
+// Engine returns the current rules. If reloading fails a previously loaded
+// Engine is returned along with the error, and the failure is reused for a
+// few seconds rather than querying again on every call.
+func (f *Filter) Engine(ctx context.Context) (*Engine, error) {
+	if engine, fresh, err := f.cached(); fresh {
+		return engine, err
+	}
+	if !f.loading.TryLock() {
+		f.mu.Lock()
+		engine := f.engine
+		f.mu.Unlock()
+		if engine != nil {
+			return engine, nil
+		}
+		f.loading.Lock()
+	}
+	defer f.loading.Unlock()
+	// Another caller may have reloaded while this one waited.
+	if engine, fresh, err := f.cached(); fresh {
+		return engine, err
+	}
+
+	f.mu.Lock()
+	generation := f.generation
+	f.mu.Unlock()
+	engine, err := f.load(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next := f.now().Add(f.reload)
+	if err != nil {
+		f.err = err
+		next = f.now().Add(min(retryAfterFailure, f.reload))
+	} else {
+		f.engine, f.err = engine, nil
+	}
+	// A rule changed during the load may not be in what was read.
+	if f.generation == generation {
+		f.nextLoad = next
+	}
+	return f.engine, f.err
+}
+
+func (f *Filter) cached() (engine *Engine, fresh bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.now().Before(f.nextLoad) {
+		return f.engine, true, f.err
+	}
+	return nil, false, nil
+}
+
+func (f *Filter) load(ctx context.Context) (*Engine, error) {
+	rows, err := f.queries.ListModerationRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading moderation rules: %w", err)
+	}
+	rules := make([]Rule, len(rows))
+	for i, row := range rows {
+		rules[i] = FromDB(row)
+	}
+	return Compile(rules)
+}
+
+// Invalidate makes the next call to Engine reload the rules.
+func (f *Filter) Invalidate() {
+	f.mu.Lock()
+	f.nextLoad = time.Time{}
+	f.generation++
+	f.mu.Unlock()
+}
+
+func FromDB(r database.ModerationRule) Rule {
+	return Rule{ID: r.ID, Kind: Kind(r.Kind), Pattern: r.Pattern, Action: Action(r.Action)}
+}
@@ -0,0 +1,160 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+)
+
+func rule(kind Kind, pattern string, action Action) Rule {
+	return Rule{ID: uuid.New(), Kind: kind, Pattern: pattern, Action: action}
+}
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"Kerfuffle!":        "kerfuffle",
+		"K3RFUFFL3":         "kerfuffle",
+		"k.e.r.f.u.f.f.l.e": "kerfuffle",
+		"$h@rb3rt":          "sharbert",
+		"fórnáx":            "fornax",
+		"f0rn4x":            "fornax",
+		"1337":              "ieet",
+		"...":               "",
+	} {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	engine, err := Compile([]Rule{
+		rule(KindWord, "kerfuffle", ActionMask),
+		rule(KindWord, "sharbert", ActionMask),
+		rule(KindWord, "fornax", ActionHold),
+		rule(KindWord, "spamword", ActionReject),
+		rule(KindRegex, `buy\s+now`, ActionMask),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in         string
+		wantText   string
+		wantAction Action
+	}{
+		{"hello world", "hello world", ""},
+		{"what a kerfuffle", "what a ****", ActionMask},
+		{"Kerfuffle! that was close", "****! that was close", ActionMask},
+		{"(k3rfuffl3)", "(****)", ActionMask},
+		{"kerfuffles are fine", "kerfuffles are fine", ""},
+		{"sharbert\nand  kerfuffle", "****\nand  ****", ActionMask},
+		{"a kerfuffle about Fornax", "a **** about Fornax", ActionHold},
+		{"s.p.a.m.w.o.r.d and kerfuffle", "s.p.a.m.w.o.r.d and ****", ActionReject},
+		{"BUY   NOW!", "****!", ActionMask},
+	}
+	for _, tt := range tests {
+		got := engine.Apply(tt.in)
+		if got.Text != tt.wantText || got.Action != tt.wantAction {
+			t.Errorf("Apply(%q) = %q, %q; want %q, %q", tt.in, got.Text, got.Action, tt.wantText, tt.wantAction)
+		}
+	}
+}
+
+func TestApplyReportsEachRuleOnce(t *testing.T) {
+	word := rule(KindWord, "kerfuffle", ActionMask)
+	re := rule(KindRegex, "ker", ActionHold)
+	engine, err := Compile([]Rule{word, re})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := engine.Apply("kerfuffle kerfuffle kernel")
+	if !slices.Equal(got.Matched, []Rule{word, re}) {
+		t.Errorf("Matched = %v, want the word rule then the regex rule", got.Matched)
+	}
+}
+
+func TestCompileKeepsMostSevereDuplicate(t *testing.T) {
+	engine, err := Compile([]Rule{
+		rule(KindWord, "fornax", ActionReject),
+		rule(KindWord, "F0RNAX", ActionMask),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.Apply("fornax").Action; got != ActionReject {
+		t.Errorf("Action = %q, want reject", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		rule    Rule
+		wantErr bool
+	}{
+		{rule(KindWord, "kerfuffle", ActionMask), false},
+		{rule(KindRegex, `\bfoo\d+`, ActionReject), false},
+		{rule(KindWord, "!!!", ActionMask), true},
+		{rule(KindRegex, `(unclosed`, ActionMask), true},
+		{rule(KindRegex, `a*`, ActionMask), true},
+		{rule("phrase", "foo", ActionMask), true},
+		{rule(KindWord, "foo", "delete"), true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.Check(); (err != nil) != tt.wantErr {
+			t.Errorf("Check(%s %q %s) = %v, want error %v", tt.rule.Kind, tt.rule.Pattern, tt.rule.Action, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNilEngine(t *testing.T) {
+	var e *Engine
+	if got := e.Apply("kerfuffle"); got.Text != "kerfuffle" || got.Action != "" {
+		t.Errorf("nil Engine changed the text: %+v", got)
+	}
+}
+
+// failingDB counts queries and fails every one of them.
+type failingDB struct {
+	database.DBTX
+	queries int
+}
+
+func (db *failingDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	db.queries++
+	return nil, errors.New("connection refused")
+}
+
+func TestFilterBacksOffAfterFailure(t *testing.T) {
+	db := &failingDB{}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFilter(database.New(db), 30*time.Second)
+	f.now = func() time.Time { return now }
+
+	for range 3 {
+		if engine, err := f.Engine(context.Background()); engine != nil || err == nil {
+			t.Fatalf("Engine() = %v, %v; expected an error", engine, err)
+		}
+	}
+	if db.queries != 1 {
+		t.Errorf("queried %d times during backoff, expected 1", db.queries)
+	}
+
+	now = now.Add(retryAfterFailure)
+	f.Engine(context.Background())
+	if db.queries != 2 {
+		t.Errorf("queried %d times after backoff, expected 2", db.queries)
+	}
+
+	f.Invalidate()
+	f.Engine(context.Background())
+	if db.queries != 3 {
+		t.Errorf("queried %d times after Invalidate, expected 3", db.queries)
+	}
+}
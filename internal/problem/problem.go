@@ -0,0 +1,141 @@
+// Package problem turns errors into RFC 7807 application/problem+json
+// responses with stable, machine-readable codes.
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const ContentType = "application/problem+json"
+
+// Code identifies a kind of problem. Codes are part of the API: clients
+// match on them, so existing values must not change.
+type Code string
+
+const (
+	CodeInvalidJSON          Code = "invalid_json"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeForbidden            Code = "forbidden"
+	CodeAccountSuspended     Code = "account_suspended"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodeRateLimited          Code = "rate_limited"
+	CodeLoginThrottled       Code = "login_throttled"
+	CodeInternal             Code = "internal_error"
+)
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error that knows how to present itself to API clients.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []FieldError
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", e.Code, e.Detail)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %s %s", f.Field, f.Message)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func InvalidJSON(err error) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidJSON, Detail: "Request body is not valid JSON", Err: err}
+}
+
+func Validation(fields ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: "Request failed validation", Fields: fields}
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: "Something went wrong", Err: err}
+}
+
+// From converts err into an *Error. A *Error anywhere in the chain is used
+// as is, sql.ErrNoRows becomes not_found and anything else is internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: "Resource not found", Err: err}
+	}
+	return Internal(err)
+}
+
+// Problem is the response body defined by RFC 7807, with code and errors
+// as extension members.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// Write sends err as a problem response. r may be nil when the request is
+// not at hand; it only supplies the instance member.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	p := Problem{
+		Type:   "/problems/" + string(e.Code),
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	dat, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(e.Status)
+	w.Write(dat)
+}
@@ -0,0 +1,61 @@
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/chirps", nil)
+	w := httptest.NewRecorder()
+	Write(w, r, Validation(FieldError{Field: "body", Code: "required", Message: "must not be empty"}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentType)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != CodeValidationFailed || p.Status != 400 || p.Instance != "/api/chirps" ||
+		p.Type != "/problems/validation_failed" || p.Title != "Bad Request" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "body" {
+		t.Errorf("errors = %+v, want one error for body", p.Errors)
+	}
+}
+
+func TestFrom(t *testing.T) {
+	wrapped := fmt.Errorf("handler: %w", Forbidden("nope"))
+	if e := From(wrapped); e.Status != http.StatusForbidden || e.Code != CodeForbidden {
+		t.Errorf("From(wrapped forbidden) = %+v", e)
+	}
+	if e := From(sql.ErrNoRows); e.Status != http.StatusNotFound {
+		t.Errorf("From(sql.ErrNoRows) status = %d, want 404", e.Status)
+	}
+
+	cause := errors.New("connection refused")
+	e := From(cause)
+	if e.Status != http.StatusInternalServerError || !errors.Is(e, cause) {
+		t.Errorf("From(other) = %+v, want internal error wrapping the cause", e)
+	}
+
+	w := httptest.NewRecorder()
+	Write(w, nil, cause)
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Detail == cause.Error() {
+		t.Error("internal error cause leaked to the client")
+	}
+}
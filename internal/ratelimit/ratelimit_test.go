@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, _ := store.Take(ctx, "ip:1", limit)
+		if !res.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("request %d: Remaining = %d, expected %d", i+1, res.Remaining, 2-i)
+		}
+	}
+
+	res, _ := store.Take(ctx, "ip:1", limit)
+	if res.Allowed {
+		t.Fatal("fourth request should be rejected")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, expected 1s", res.RetryAfter)
+	}
+
+	if other, _ := store.Take(ctx, "ip:2", limit); !other.Allowed {
+		t.Errorf("buckets must be independent per key")
+	}
+
+	now = now.Add(time.Second)
+	if res, _ := store.Take(ctx, "ip:1", limit); !res.Allowed {
+		t.Errorf("a token should have refilled after one second")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, Result{
+		Allowed:    false,
+		Limit:      10,
+		Remaining:  0,
+		Reset:      5500 * time.Millisecond,
+		RetryAfter: 200 * time.Millisecond,
+	})
+	expected := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "6",
+		"Retry-After":         "1",
+	}
+	for key, val := range expected {
+		if got := h.Get(key); got != val {
+			t.Errorf("%s = %q, expected %q", key, got, val)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package request decodes and validates JSON request bodies, reporting
+// every problem as a problem.Error.
+//
+// Fields are validated from their validate struct tag, a comma-separated
+// list of rules:
+//
+//	required     must be present and, for strings, not blank
+//	email        a bare email address
+//	uuid         a UUID
+//	ip           an IPv4 or IPv6 address
+//	url          an absolute http or https URL
+//	min=N        at least N characters (strings) or at least N (integers)
+//	max=N        at most N characters (strings) or at most N (integers)
+//	maxbytes=N   at most N bytes when UTF-8 encoded
+//
+// Rules other than required are skipped for empty optional fields. Nested
+// structs are validated with dotted field names, e.g. data.user_id.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+// MaxBodyBytes bounds every request body read by Decode.
+const MaxBodyBytes = 64 << 10
+
+// ErrEmptyBody is wrapped by the error Decode returns for a request with
+// no body, so handlers whose body is optional can tell it apart.
+var ErrEmptyBody = errors.New("empty request body")
+
+// Decode reads exactly one JSON value from r's body into dst, rejecting
+// unknown fields, oversized bodies and non-JSON content types, then
+// validates dst. A missing Content-Type is accepted.
+func Decode(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decode(w, r, dst, true)
+}
+
+// DecodeLoose is Decode without the unknown field check, for payloads from
+// third parties that may add fields at any time.
+func DecodeLoose(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decode(w, r, dst, false)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any, strict bool) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+				"Content-Type must be application/json")
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err)
+		}
+		return &problem.Error{
+			Status: http.StatusBadRequest,
+			Code:   problem.CodeInvalidJSON,
+			Detail: "Request body must contain a single JSON value",
+			Err:    err,
+		}
+	}
+	return Validate(dst)
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &problem.Error{
+			Status: http.StatusBadRequest,
+			Code:   problem.CodeInvalidJSON,
+			Detail: "Request body is empty",
+			Err:    ErrEmptyBody,
+		}
+	case errors.As(err, &maxErr):
+		return &problem.Error{
+			Status: http.StatusRequestEntityTooLarge,
+			Code:   problem.CodePayloadTooLarge,
+			Detail: fmt.Sprintf("Request body must be at most %d bytes", maxErr.Limit),
+			Err:    err,
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return problem.Validation(problem.FieldError{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: "must be " + jsonKind(typeErr.Type),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return problem.Validation(problem.FieldError{
+			Field:   field,
+			Code:    "unknown_field",
+			Message: "is not a recognized field",
+		})
+	}
+	return problem.InvalidJSON(err)
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// Validate checks v, a struct or pointer to one, against its validate
+// tags and reports every failing field at once.
+func Validate(v any) error {
+	var fields []problem.FieldError
+	validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", &fields)
+	if len(fields) > 0 {
+		return problem.Validation(fields...)
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func validateStruct(v reflect.Value, prefix string, fields *[]problem.FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fv := v.Field(i)
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			validateStruct(fv, prefix+name+".", fields)
+			continue
+		}
+		if rules := sf.Tag.Get("validate"); rules != "" {
+			if fe, ok := check(fv, rules); !ok {
+				fe.Field = prefix + name
+				*fields = append(*fields, fe)
+			}
+		}
+	}
+}
+
+// check applies rules to v and returns the first failure.
+func check(v reflect.Value, rules string) (problem.FieldError, bool) {
+	empty := v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "")
+	if empty {
+		if strings.Contains(","+rules+",", ",required,") {
+			return problem.FieldError{Code: "required", Message: "is required"}, false
+		}
+		return problem.FieldError{}, true
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		var fe problem.FieldError
+		switch name {
+		case "required":
+			continue
+		case "email":
+			if !IsEmail(v.String()) {
+				fe = problem.FieldError{Code: "invalid_email", Message: "must be an email address"}
+			}
+		case "uuid":
+			if _, err := uuid.Parse(v.String()); err != nil {
+				fe = problem.FieldError{Code: "invalid_uuid", Message: "must be a UUID"}
+			}
+		case "ip":
+			if net.ParseIP(v.String()) == nil {
+				fe = problem.FieldError{Code: "invalid_ip", Message: "must be an IP address"}
+			}
+		case "url":
+			if !IsURL(v.String()) {
+				fe = problem.FieldError{Code: "invalid_url", Message: "must be an http or https URL"}
+			}
+		case "min", "max", "maxbytes":
+			fe = checkBound(v, name, mustAtoi(rule, arg))
+		default:
+			panic(fmt.Sprintf("request: unknown validate rule %q", rule))
+		}
+		if fe.Code != "" {
+			return fe, false
+		}
+	}
+	return problem.FieldError{}, true
+}
+
+func checkBound(v reflect.Value, rule string, limit int) problem.FieldError {
+	if v.Kind() == reflect.String {
+		switch n := utf8.RuneCountInString(v.String()); {
+		case rule == "min" && n < limit:
+			return problem.FieldError{Code: "too_short", Message: fmt.Sprintf("must be at least %d characters", limit)}
+		case rule == "max" && n > limit:
+			return problem.FieldError{Code: "too_long", Message: fmt.Sprintf("must be at most %d characters", limit)}
+		case rule == "maxbytes" && len(v.String()) > limit:
+			return problem.FieldError{Code: "too_long", Message: fmt.Sprintf("must be at most %d bytes", limit)}
+		}
+		return problem.FieldError{}
+	}
+	if !v.CanInt() {
+		panic(fmt.Sprintf("request: %s rule on unsupported kind %s", rule, v.Kind()))
+	}
+	switch n := v.Int(); {
+	case rule == "min" && n < int64(limit):
+		return problem.FieldError{Code: "out_of_range", Message: fmt.Sprintf("must be at least %d", limit)}
+	case rule == "max" && n > int64(limit):
+		return problem.FieldError{Code: "out_of_range", Message: fmt.Sprintf("must be at most %d", limit)}
+	}
+	return problem.FieldError{}
+}
+
+func mustAtoi(rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic(fmt.Sprintf("request: invalid validate rule %q", rule))
+	}
+	return n
+}
+
+// IsEmail reports whether s is a bare email address such as
+// user@example.com, without a display name or angle brackets.
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Name == "" && addr.Address == s
+}
+
+// IsURL reports whether s is an absolute http or https URL with a host.
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
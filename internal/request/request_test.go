@@ -0,0 +1,149 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+type params struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72"`
+	Age      int    `json:"age" validate:"max=150"`
+	Data     struct {
+		UserID string `json:"user_id" validate:"uuid"`
+	} `json:"data"`
+}
+
+func decodeBody(t *testing.T, contentType, body string, loose bool) (params, *problem.Error) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	var p params
+	var err error
+	if loose {
+		err = DecodeLoose(httptest.NewRecorder(), r, &p)
+	} else {
+		err = Decode(httptest.NewRecorder(), r, &p)
+	}
+	if err == nil {
+		return p, nil
+	}
+	var pe *problem.Error
+	if !errors.As(err, &pe) {
+		t.Fatalf("error %v is not a *problem.Error", err)
+	}
+	return p, pe
+}
+
+func TestDecode(t *testing.T) {
+	const valid = `{"email":"a@example.com","password":"hunter2hunter2"}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		loose       bool
+		wantStatus  int
+		wantCode    problem.Code
+		wantFields  []string
+	}{
+		{name: "valid", contentType: "application/json", body: valid},
+		{name: "no content type", body: valid},
+		{name: "content type with charset", contentType: "application/json; charset=utf-8", body: valid},
+		{name: "wrong content type", contentType: "text/plain", body: valid, wantStatus: 415, wantCode: problem.CodeUnsupportedMediaType},
+		{name: "empty", body: "", wantStatus: 400, wantCode: problem.CodeInvalidJSON},
+		{name: "malformed", body: `{"email":`, wantStatus: 400, wantCode: problem.CodeInvalidJSON},
+		{name: "trailing data", body: valid + `{}`, wantStatus: 400, wantCode: problem.CodeInvalidJSON},
+		{name: "unknown field", body: `{"email":"a@example.com","password":"hunter2hunter2","admin":true}`,
+			wantStatus: 400, wantCode: problem.CodeValidationFailed, wantFields: []string{"admin"}},
+		{name: "unknown field loose", body: `{"email":"a@example.com","password":"hunter2hunter2","admin":true}`, loose: true},
+		{name: "wrong type", body: `{"email":"a@example.com","password":"hunter2hunter2","age":"old"}`,
+			wantStatus: 400, wantCode: problem.CodeValidationFailed, wantFields: []string{"age"}},
+		{name: "too large", body: `{"email":"` + strings.Repeat("a", MaxBodyBytes) + `"}`, wantStatus: 413, wantCode: problem.CodePayloadTooLarge},
+		{name: "every rule failure reported", body: `{"email":"nope","password":"short","age":200,"data":{"user_id":"x"}}`,
+			wantStatus: 400, wantCode: problem.CodeValidationFailed, wantFields: []string{"email", "password", "age", "data.user_id"}},
+		{name: "required", body: `{"email":"  "}`,
+			wantStatus: 400, wantCode: problem.CodeValidationFailed, wantFields: []string{"email", "password"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, pe := decodeBody(t, tt.contentType, tt.body, tt.loose)
+			if tt.wantStatus == 0 {
+				if pe != nil {
+					t.Fatalf("unexpected error: %v", pe)
+				}
+				return
+			}
+			if pe == nil {
+				t.Fatal("expected an error")
+			}
+			if pe.Status != tt.wantStatus || pe.Code != tt.wantCode {
+				t.Errorf("got %d %s, want %d %s", pe.Status, pe.Code, tt.wantStatus, tt.wantCode)
+			}
+			var got []string
+			for _, f := range pe.Fields {
+				got = append(got, f.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestDecodeEmptyBody(t *testing.T) {
+	_, pe := decodeBody(t, "", "", false)
+	if !errors.Is(pe, ErrEmptyBody) {
+		t.Errorf("empty body error %v does not wrap ErrEmptyBody", pe)
+	}
+}
+
+func TestValidateCountsRunes(t *testing.T) {
+	type chirp struct {
+		Body string `json:"body" validate:"max=3"`
+	}
+	if err := Validate(chirp{Body: "héé"}); err != nil {
+		t.Errorf("3 runes rejected: %v", err)
+	}
+	if err := Validate(chirp{Body: "abcd"}); err == nil {
+		t.Error("4 runes accepted")
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	for s, want := range map[string]bool{
+		"a@example.com":      true,
+		"a.b+c@example.co":   true,
+		"nope":               false,
+		"@example.com":       false,
+		"A <a@example.com>":  false,
+		"<a@example.com>":    false,
+		"a@example.com, b@c": false,
+	} {
+		if got := IsEmail(s); got != want {
+			t.Errorf("IsEmail(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	for s, want := range map[string]bool{
+		"https://example.com/a.png": true,
+		"http://example.com":        true,
+		"example.com/a.png":         false,
+		"/a.png":                    false,
+		"javascript:alert(1)":       false,
+		"ftp://example.com/a.png":   false,
+		"https://":                  false,
+	} {
+		if got := IsURL(s); got != want {
+			t.Errorf("IsURL(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package seed generates deterministic fake users, follows and chirps for
+// demos and load tests. The same seed and sizes always produce the same
+// IDs, emails, timestamps and bodies, so loading a dataset twice is a
+// no-op.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/database"
+)
+
+// Password is the password of every generated user.
+const Password = "password"
+
+// namespace scopes generated IDs so they never collide with real UUIDs.
+var namespace = uuid.MustParse("6f1c1c52-3c2e-4f0e-9a57-0c4d3b7e2a11")
+
+// epoch is the fixed start of generated timestamps.
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type Options struct {
+	Seed          uint64
+	Users         int
+	ChirpsPerUser int
+	// FollowsPerUser is capped at the number of other users.
+	FollowsPerUser int
+}
+
+type User struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+type Chirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Dataset struct {
+	Users   []User
+	Follows []Follow
+	Chirps  []Chirp
+}
+
+// Counts is how many rows Load inserted.
+type Counts struct {
+	Users   int64
+	Follows int64
+	Chirps  int64
+}
+
+var (
+	firstNames = []string{"ada", "alan", "barbara", "dennis", "edsger", "frances", "grace", "ken", "linus", "margaret", "niklaus", "radia", "rob", "sophie", "tim", "vint"}
+	lastNames  = []string{"brooks", "hopper", "knuth", "lamport", "liskov", "lovelace", "perlman", "pike", "ritchie", "thompson", "torvalds", "turing", "wilson", "wirth"}
+	openers    = []string{"Just shipped", "Can't stop thinking about", "Hot take:", "Today I learned about", "Reminder to self:", "Still debugging", "Finally understood", "Reading up on"}
+	subjects   = []string{"goroutines", "the new release", "my sourdough starter", "database indexes", "a tiny web server", "rate limiters", "coffee", "the weekend hike", "unicode", "code review"}
+	closers    = []string{"and it was worth it.", "Send help.", "10/10 would do again.", "More soon.", "Thoughts?", "Who knew?", "", "#chirpy"}
+)
+
+// Generate builds the dataset for opts. It does not touch the database.
+func Generate(opts Options) Dataset {
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	var data Dataset
+	for i := range opts.Users {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+		user := User{
+			ID:          id(opts.Seed, "user", i),
+			CreatedAt:   epoch.Add(time.Duration(rng.IntN(24*30)) * time.Hour),
+			Email:       fmt.Sprintf("%s.%s.%d.%d@example.com", first, last, opts.Seed, i),
+			IsChirpyRed: rng.IntN(5) == 0,
+		}
+		data.Users = append(data.Users, user)
+
+		for j := range opts.ChirpsPerUser {
+			data.Chirps = append(data.Chirps, Chirp{
+				ID:        id(opts.Seed, fmt.Sprintf("chirp-%d", i), j),
+				CreatedAt: user.CreatedAt.Add(time.Duration(j)*6*time.Hour + time.Duration(rng.IntN(6*60))*time.Minute),
+				Body:      body(rng),
+				UserID:    user.ID,
+			})
+		}
+	}
+
+	// Follows are drawn after everything else so that adding them left
+	// the users and chirps of existing seeds unchanged.
+	follows := min(opts.FollowsPerUser, max(opts.Users-1, 0))
+	for i, follower := range data.Users {
+		picked := map[int]bool{}
+		for len(picked) < follows {
+			offset := 1 + rng.IntN(opts.Users-1)
+			if picked[offset] {
+				continue
+			}
+			picked[offset] = true
+			followed := data.Users[(i+offset)%opts.Users]
+			data.Follows = append(data.Follows, Follow{
+				FollowerID: follower.ID,
+				FollowedID: followed.ID,
+				CreatedAt:  later(follower.CreatedAt, followed.CreatedAt).Add(time.Duration(rng.IntN(24*7)) * time.Hour),
+			})
+		}
+	}
+	return data
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func id(seed uint64, kind string, n int) uuid.UUID {
+	return uuid.NewSHA1(namespace, fmt.Appendf(nil, "%d/%s/%d", seed, kind, n))
+}
+
+func body(rng *rand.Rand) string {
+	parts := []string{
+		openers[rng.IntN(len(openers))],
+		subjects[rng.IntN(len(subjects))],
+		closers[rng.IntN(len(closers))],
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// Load inserts data in one transaction, skipping rows that already exist.
+func Load(ctx context.Context, db *sql.DB, data Dataset) (Counts, error) {
+	var counts Counts
+	hash, err := auth.HashPassword(Password)
+	if err != nil {
+		return counts, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	for _, u := range data.Users {
+		n, err := q.SeedUser(ctx, database.SeedUserParams{
+			ID:             u.ID,
+			CreatedAt:      u.CreatedAt,
+			Email:          u.Email,
+			HashedPassword: hash,
+			IsChirpyRed:    sql.NullBool{Bool: u.IsChirpyRed, Valid: true},
+		})
+		if err != nil {
+			return counts, fmt.Errorf("error inserting user %s: %w", u.Email, err)
+		}
+		counts.Users += n
+	}
+	for _, f := range data.Follows {
+		n, err := q.SeedFollow(ctx, database.SeedFollowParams{
+			FollowerID: f.FollowerID,
+			FollowedID: f.FollowedID,
+			CreatedAt:  f.CreatedAt,
+		})
+		if err != nil {
+			return counts, fmt.Errorf("error inserting follow %s -> %s: %w", f.FollowerID, f.FollowedID, err)
+		}
+		counts.Follows += n
+	}
+	for _, c := range data.Chirps {
+		n, err := q.SeedChirp(ctx, database.SeedChirpParams{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			Body:      c.Body,
+			UserID:    c.UserID,
+		})
+		if err != nil {
+			return counts, fmt.Errorf("error inserting chirp %s: %w", c.ID, err)
+		}
+		counts.Chirps += n
+	}
+	return counts, tx.Commit()
+}
@@ -0,0 +1,58 @@
+package seed
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	opts := Options{Seed: 42, Users: 30, ChirpsPerUser: 5, FollowsPerUser: 4}
+	a, b := Generate(opts), Generate(opts)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("Generate returned different data for the same options")
+	}
+	if len(a.Users) != 30 || len(a.Chirps) != 150 {
+		t.Fatalf("got %d users and %d chirps, want 30 and 150", len(a.Users), len(a.Chirps))
+	}
+
+	emails := map[string]bool{}
+	for _, u := range a.Users {
+		if emails[u.Email] {
+			t.Errorf("duplicate email %s", u.Email)
+		}
+		emails[u.Email] = true
+	}
+	for _, c := range a.Chirps {
+		if len(c.Body) == 0 || len(c.Body) > 140 {
+			t.Errorf("chirp body %q is not 1-140 characters", c.Body)
+		}
+	}
+
+	if len(a.Follows) != 120 {
+		t.Fatalf("got %d follows, want 120", len(a.Follows))
+	}
+	follows := map[[2]string]bool{}
+	for _, f := range a.Follows {
+		key := [2]string{f.FollowerID.String(), f.FollowedID.String()}
+		if f.FollowerID == f.FollowedID {
+			t.Errorf("user %s follows themselves", f.FollowerID)
+		}
+		if follows[key] {
+			t.Errorf("duplicate follow %s -> %s", key[0], key[1])
+		}
+		follows[key] = true
+	}
+
+	withoutFollows := Generate(Options{Seed: 42, Users: 30, ChirpsPerUser: 5})
+	if !reflect.DeepEqual(withoutFollows.Chirps, a.Chirps) {
+		t.Error("adding follows changed the generated chirps")
+	}
+	if few := Generate(Options{Seed: 42, Users: 3, FollowsPerUser: 10}); len(few.Follows) != 6 {
+		t.Errorf("got %d follows among 3 users, want 6", len(few.Follows))
+	}
+
+	other := Generate(Options{Seed: 43, Users: 1})
+	if other.Users[0].ID == a.Users[0].ID {
+		t.Error("different seeds produced the same user ID")
+	}
+}
@@ -0,0 +1,74 @@
+// Package testdb hands tests a migrated database of their own.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iahta/chirpy/sql/schema"
+	"github.com/lib/pq"
+)
+
+// Open connects to the database in CHIRPY_TEST_DB_URL, or skips the test
+// when it is unset. The connection uses a fresh Postgres schema named
+// name, migrated to the latest version, so test packages running in
+// parallel don't drop each other's tables.
+func Open(t testing.TB, name string) *sql.DB {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	ident := pq.QuoteIdentifier(name)
+	if _, err := admin.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+ident+" CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+ident); err != nil {
+		t.Fatal(err)
+	}
+
+	scopedURL, err := withSearchPath(dbURL, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", scopedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	provider, err := schema.NewProvider(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// withSearchPath adds a search_path runtime parameter to a lib/pq
+// connection string, in either URL or key=value form.
+func withSearchPath(dbURL, name string) (string, error) {
+	if !strings.HasPrefix(dbURL, "postgres://") && !strings.HasPrefix(dbURL, "postgresql://") {
+		return dbURL + " search_path=" + name, nil
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("search_path", name)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,32 @@
+package testdb
+
+import "testing"
+
+func TestWithSearchPath(t *testing.T) {
+	testCases := []struct {
+		dbURL    string
+		expected string
+	}{
+		{
+			"postgres://chirpy@localhost:5432/chirpy_test?sslmode=disable",
+			"postgres://chirpy@localhost:5432/chirpy_test?search_path=lockout&sslmode=disable",
+		},
+		{
+			"postgresql://localhost/chirpy_test",
+			"postgresql://localhost/chirpy_test?search_path=lockout",
+		},
+		{
+			"host=localhost dbname=chirpy_test sslmode=disable",
+			"host=localhost dbname=chirpy_test sslmode=disable search_path=lockout",
+		},
+	}
+	for _, tc := range testCases {
+		got, err := withSearchPath(tc.dbURL, "lockout")
+		if err != nil {
+			t.Fatalf("withSearchPath(%q): %v", tc.dbURL, err)
+		}
+		if got != tc.expected {
+			t.Errorf("withSearchPath(%q) = %q, expected %q", tc.dbURL, got, tc.expected)
+		}
+	}
+}
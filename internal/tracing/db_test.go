@@ -0,0 +1,20 @@
+package tracing
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"-- name: CreateUser :one\nINSERT INTO users (id) VALUES ($1)", "CreateUser"},
+		{"\n-- name: DeleteUsers :exec\nDELETE FROM users\n", "DeleteUsers"},
+		{"select 1", "SELECT"},
+		{"", "query"},
+	}
+	for _, tc := range testCases {
+		if got := QueryName(tc.query); got != tc.expected {
+			t.Errorf("QueryName(%q) = %q, expected %q", tc.query, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/jobs"
+)
+
+const (
+	jobPurgeRefreshTokens    = "purge_refresh_tokens"
+	jobPurgeRateLimitBuckets = "purge_rate_limit_buckets"
+	jobPurgeLoginAttempts    = "purge_login_attempts"
+	jobPurgeUnattachedMedia  = "purge_unattached_media"
+)
+
+func (cfg *apiConfig) registerJobs(runner *jobs.Runner) {
+	runner.Register(jobPurgeRefreshTokens, cfg.purgeRefreshTokensJob)
+	runner.Register(jobPurgeRateLimitBuckets, cfg.purgeRateLimitBucketsJob)
+	runner.Register(jobPurgeLoginAttempts, cfg.purgeLoginAttemptsJob)
+	runner.Register(jobPurgeUnattachedMedia, cfg.purgeUnattachedMediaJob)
+	runner.Register(jobSendEmail, cfg.sendEmailJob)
+	runner.Register(jobPublishChirp, cfg.publishChirpJob)
+}
+
+// scheduleJobs enqueues the recurring jobs. It is safe to call on every
+// start because each run is deduplicated by its scheduled day.
+func (cfg *apiConfig) scheduleJobs(ctx context.Context) {
+	for _, kind := range []string{jobPurgeRefreshTokens, jobPurgeRateLimitBuckets, jobPurgeLoginAttempts, jobPurgeUnattachedMedia} {
+		if err := cfg.scheduleDaily(ctx, kind); err != nil {
+			slog.Error("Unable to schedule job", "kind", kind, "error", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeRefreshTokensJob(ctx context.Context, job database.Job) error {
+	n, err := cfg.database.DeleteExpiredRefreshTokens(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Purged expired or revoked refresh tokens", "count", n)
+	return cfg.scheduleDaily(ctx, job.Kind)
+}
+
+func (cfg *apiConfig) purgeRateLimitBucketsJob(ctx context.Context, job database.Job) error {
+	n, err := cfg.database.DeleteIdleRateLimitBuckets(ctx, time.Now().UTC().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	slog.Info("Purged idle rate limit buckets", "count", n)
+	return cfg.scheduleDaily(ctx, job.Kind)
+}
+
+func (cfg *apiConfig) purgeLoginAttemptsJob(ctx context.Context, job database.Job) error {
+	n, err := cfg.database.DeleteStaleLoginAttempts(ctx, time.Now().UTC().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	slog.Info("Purged stale login attempts", "count", n)
+	return cfg.scheduleDaily(ctx, job.Kind)
+}
+
+// scheduleDaily enqueues the next run of kind at the coming midnight UTC.
+func (cfg *apiConfig) scheduleDaily(ctx context.Context, kind string) error {
+	next := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	_, err := jobs.Enqueue(ctx, cfg.database, kind, struct{}{}, jobs.EnqueueOptions{
+		RunAt:     next,
+		DedupeKey: kind + ":" + next.Format(time.DateOnly),
+	})
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return nil
+	}
+	return err
+}
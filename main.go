@@ -1,76 +1,219 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/blob"
+	"github.com/iahta/chirpy/internal/config"
 	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/jobs"
+	"github.com/iahta/chirpy/internal/lockout"
+	"github.com/iahta/chirpy/internal/mailer"
+	"github.com/iahta/chirpy/internal/media"
+	"github.com/iahta/chirpy/internal/metrics"
+	"github.com/iahta/chirpy/internal/moderation"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/ratelimit"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	database       *database.Queries
+	metrics        *metrics.Metrics
 	platform       string
 	jwtSecret      string
 	polkaKey       string
+	adminAPIKey    string
+	trustProxy     bool
+	mailer         mailer.Mailer
+	loginGuard     *lockout.Guard
+	jobs           *jobs.Runner
+	moderation     *moderation.Filter
+	blobs          blob.Store
+	mediaLimits    media.Limits
+	maxUploadBytes int
+
+	jwtLifetime          time.Duration
+	refreshTokenLifetime time.Duration
 }
 
 func main() {
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
-	JWT_Secret := os.Getenv("JWT_SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+	args := os.Args[1:]
+	var command string
+	if len(args) > 0 && (args[0] == "migrate" || args[0] == "admin") {
+		command, args = args[0], args[1:]
 	}
-	if JWT_Secret == "" {
-		log.Fatal("JWT_SECRET must be set")
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	db, err := sql.Open("postgres", dbURL)
+	if command != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		if command == "migrate" {
+			err = runMigrate(ctx, cfg, os.Stdout)
+		} else {
+			err = runAdmin(ctx, cfg, os.Stdin, os.Stdout)
+		}
+		stop()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(cfg.Args) > 0 {
+		log.Fatalf("Unknown command %q", cfg.Args[0])
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(cfg.Logger(os.Stderr))
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Unable to call database: %v", err)
 	}
-	dbQueries := database.New(db)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.MigrateOnStart {
+		if err := migrateOnStart(ctx, db); err != nil {
+			log.Fatalf("Unable to apply migrations: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Unable to set up tracing: %v", err)
+	}
+	dbQueries := database.New(tracing.WrapDB(db))
 	ok := []byte("OK")
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		database:       dbQueries,
-		platform:       platform,
-		jwtSecret:      JWT_Secret,
-		polkaKey:       polkaKey,
+		fileserverHits:       atomic.Int32{},
+		db:                   db,
+		database:             dbQueries,
+		metrics:              metrics.New(db),
+		platform:             cfg.Platform,
+		jwtSecret:            cfg.JWTSecret,
+		jwtLifetime:          cfg.JWTLifetime,
+		refreshTokenLifetime: cfg.RefreshTokenLifetime,
+		polkaKey:             cfg.PolkaKey,
+		adminAPIKey:          cfg.AdminAPIKey,
+		trustProxy:           cfg.RateLimit.TrustProxy,
+		mailer:               newMailer(cfg.Mail),
+		loginGuard: lockout.New(dbQueries, lockout.Policy{
+			DelayAfter:      cfg.Login.DelayAfter,
+			MaxDelay:        cfg.Login.MaxDelay,
+			LockoutAfter:    cfg.Login.LockoutAfter,
+			LockoutDuration: cfg.Login.LockoutDuration,
+			Window:          cfg.Login.Window,
+		}, lockout.Policy{
+			LockoutAfter:    cfg.Login.IPLockoutAfter,
+			LockoutDuration: cfg.Login.LockoutDuration,
+			Window:          cfg.Login.Window,
+		}),
+		moderation: moderation.NewFilter(dbQueries, moderationReloadInterval),
+		blobs:      newBlobStore(cfg.Media),
+		mediaLimits: media.Limits{
+			MaxPixels:     cfg.Media.MaxPixels,
+			ThumbnailSize: cfg.Media.ThumbnailSize,
+		},
+		maxUploadBytes: cfg.Media.MaxBytes,
 	}
 
+	runner := jobs.NewRunner(dbQueries, slog.Default().With("component", "jobs"))
+	apiCfg.jobs = runner
+	apiCfg.registerJobs(runner)
+	runner.Start()
+	apiCfg.scheduleJobs(ctx)
+
 	mux := http.NewServeMux()
 	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(appHandler))
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
+	mux.Handle("GET /admin/metrics", apiCfg.requireRole(auth.RoleAdmin, apiCfg.metricsHandler))
+	mux.Handle("GET /metrics", apiCfg.metrics.Handler())
 	mux.HandleFunc("GET /api/chirps", apiCfg.retrieveHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.grabChirpHandler)
-	mux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+	mux.Handle("POST /admin/reset", apiCfg.requireRole(auth.RoleAdmin, apiCfg.resetHandler))
+	mux.Handle("POST /admin/seed", apiCfg.requireRole(auth.RoleAdmin, apiCfg.seedHandler))
+	mux.Handle("POST /admin/login-lockouts/unlock", apiCfg.requireRole(auth.RoleAdmin, apiCfg.unlockLoginHandler))
+	mux.Handle("GET /admin/moderation/rules", apiCfg.requireRole(auth.RoleAdmin, apiCfg.listRulesHandler))
+	mux.Handle("POST /admin/moderation/rules", apiCfg.requireRole(auth.RoleAdmin, apiCfg.createRuleHandler))
+	mux.Handle("PUT /admin/moderation/rules/{ruleID}", apiCfg.requireRole(auth.RoleAdmin, apiCfg.updateRuleHandler))
+	mux.Handle("DELETE /admin/moderation/rules/{ruleID}", apiCfg.requireRole(auth.RoleAdmin, apiCfg.deleteRuleHandler))
+	mux.Handle("POST /admin/moderation/test", apiCfg.requireRole(auth.RoleAdmin, apiCfg.testRulesHandler))
+	mux.Handle("GET /admin/moderation/queue", apiCfg.requireRole(auth.RoleModerator, apiCfg.moderationQueueHandler))
+	mux.Handle("POST /admin/moderation/chirps/{chirpID}/actions", apiCfg.requireRole(auth.RoleModerator, apiCfg.moderateChirpHandler))
+	mux.Handle("GET /admin/moderation/actions", apiCfg.requireRole(auth.RoleModerator, apiCfg.moderationActionsHandler))
+	mux.Handle("POST /admin/users/{userID}/suspend", apiCfg.requireRole(auth.RoleModerator, apiCfg.suspendUserHandler))
+	mux.Handle("POST /admin/users/{userID}/unsuspend", apiCfg.requireRole(auth.RoleModerator, apiCfg.unsuspendUserHandler))
 	mux.HandleFunc("POST /api/chirps", apiCfg.createChirpHandler)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUsers)
 	mux.HandleFunc("POST /api/login", apiCfg.loginHandler)
 	mux.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
 	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.profileHandler)
+	mux.HandleFunc("PATCH /api/me/profile", apiCfg.updateProfileHandler)
+	mux.HandleFunc("POST /api/me/avatar", apiCfg.uploadAvatarHandler)
+	mux.HandleFunc("POST /api/media", apiCfg.uploadMediaHandler)
+	mux.HandleFunc("GET /api/me/scheduled", apiCfg.listScheduledHandler)
+	mux.HandleFunc("POST /api/me/drafts", apiCfg.createDraftHandler)
+	mux.HandleFunc("GET /api/me/drafts", apiCfg.listDraftsHandler)
+	mux.HandleFunc("GET /api/me/drafts/{draftID}", apiCfg.getDraftHandler)
+	mux.HandleFunc("PUT /api/me/drafts/{draftID}", apiCfg.updateDraftHandler)
+	mux.HandleFunc("DELETE /api/me/drafts/{draftID}", apiCfg.deleteDraftHandler)
+	mux.HandleFunc("POST /api/me/drafts/{draftID}/publish", apiCfg.publishDraftHandler)
+	mux.HandleFunc("PATCH /api/me/scheduled/{chirpID}", apiCfg.updateScheduledHandler)
+	mux.HandleFunc("DELETE /api/me/scheduled/{chirpID}", apiCfg.cancelScheduledHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.serveMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.serveThumbnailHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.reportChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/repost", apiCfg.repostHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/repost", apiCfg.unrepostHandler)
+	mux.HandleFunc("GET /api/blocks", apiCfg.listBlocksHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.blockHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.unblockHandler)
+	mux.HandleFunc("GET /api/mutes", apiCfg.listMutesHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.muteHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.unmuteHandler)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.upgradeUser)
 
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, req *http.Request) {
@@ -78,27 +221,49 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write(ok)
 	})
+	mux.HandleFunc("GET /api/readyz", apiCfg.readyzHandler)
 
-	// Wrap the `mux` with `middlewareLog`
-	//wrappedMux := middlewareLog(mux)
+	mws := []middleware{middlewareRequestID, middlewareTracing(mux), middlewareLog, apiCfg.middlewareMetrics}
+	if cfg.RateLimit.Enabled {
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimit.Store == "postgres" {
+			store = ratelimit.NewPostgresStore(dbQueries)
+		}
+		mws = append(mws, apiCfg.middlewareRateLimit(mux, store, cfg.RateLimit))
+	}
+	handler := chain(mux, mws...)
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	server := newServer(cfg.Server, handler)
+	serveErr := runServer(ctx, server, cfg.Server.ShutdownTimeout)
+	if serveErr != nil {
+		slog.Error("Server stopped", "error", serveErr)
 	}
 
-	server.ListenAndServe()
+	workerCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := runner.Shutdown(workerCtx); err != nil {
+		slog.Error("Background jobs did not stop in time", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	if err := shutdownTracing(workerCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	if serveErr != nil {
+		os.Exit(1)
+	}
 }
 
 func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 	authHeader, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondWithError(w, r, problem.Unauthorized("Missing bearer token"))
 		return
 	}
 	refresh_token, err := cfg.database.GetRefreshToken(r.Context(), authHeader)
 	if err != nil || refresh_token.Token == "" {
-		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		respondWithError(w, r, invalidRefreshToken(err))
 		return
 	}
 	err = cfg.database.UpdateRefreshToken(r.Context(), database.UpdateRefreshTokenParams{
@@ -110,7 +275,7 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 		Token: refresh_token.Token,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh token")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 	respondWithJSON(w, http.StatusNoContent, nil)
@@ -120,29 +285,32 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 	authHeader, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondWithError(w, r, problem.Unauthorized("Missing bearer token"))
 		return
 	}
 
 	refresh_token, err := cfg.database.GetRefreshToken(r.Context(), authHeader)
 	if err != nil || refresh_token.Token == "" {
-		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		respondWithError(w, r, invalidRefreshToken(err))
 		return
 	}
 	if time.Now().After(refresh_token.ExpiresAt) || refresh_token.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token is invalid")
+		respondWithError(w, r, invalidRefreshToken(nil))
 		return
 	}
 	user, err := cfg.database.GetUserById(r.Context(), refresh_token.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Unable to retrieve User")
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if s := userSuspension(user); s.active(time.Now()) {
+		respondWithError(w, r, s.err())
 		return
 	}
 
-	exp := time.Duration(3600 * time.Second)
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, exp)
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.jwtSecret, cfg.jwtLifetime)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Unable to create new token")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 
@@ -163,8 +331,8 @@ func (t RefreshToken) IsExpired() bool {
 
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string `json:"password" validate:"required"`
+		Email    string `json:"email" validate:"required"`
 	}
 	type response struct {
 		ID           uuid.UUID `json:"id"`
@@ -172,57 +340,90 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt    time.Time `json:"updated_at"`
 		Email        string    `json:"email"`
 		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		Role         string    `json:"role"`
 		Token        string    `json:"token"`
 		RefreshToken string    `json:"refresh_token"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	// Only a loose check here: accounts created before signup validated
+	// addresses strictly must still be able to log in.
+	if !strings.Contains(params.Email, "@") {
+		cfg.metrics.LoginFailed()
+		respondWithError(w, r, errInvalidCredentials)
+		return
+	}
+
+	// Unknown emails are throttled, locked and hashed exactly like known
+	// ones so responses don't reveal which accounts exist.
+	emailKey := lockout.EmailKey(params.Email)
+	ipKey := lockout.IPKey(clientIP(r, cfg.trustProxy))
+	wait, err := cfg.loginGuard.Wait(r.Context(), emailKey, ipKey)
 	if err != nil {
-		log.Printf("Error decoding json: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		respondWithError(w, r, problem.Internal(fmt.Errorf("checking login attempts: %w", err)))
 		return
 	}
-	if !isValidEmail(params.Email) {
-		log.Printf("Invalid email: %s", params.Email)
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+	if wait > 0 {
+		cfg.metrics.LoginFailed()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, r, problem.New(http.StatusTooManyRequests, problem.CodeLoginThrottled,
+			"Too many failed login attempts, try again later"))
 		return
 	}
+
 	user, err := cfg.database.GetUserByEmail(r.Context(), params.Email)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+	found := err == nil
+	hash := dummyPasswordHash()
+	if found {
+		hash = user.HashedPassword
+	}
+	_, span := tracing.Tracer().Start(r.Context(), "auth.CheckPasswordHash")
+	err = auth.CheckPasswordHash(hash, params.Password)
+	span.End()
+	if !found || err != nil {
+		cfg.metrics.LoginFailed()
+		cfg.recordLoginFailure(r.Context(), emailKey, ipKey, user, found)
+		respondWithError(w, r, errInvalidCredentials)
 		return
 	}
-	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+	if _, err := cfg.loginGuard.Reset(r.Context(), emailKey); err != nil {
+		loggerFrom(r.Context()).Warn("Failed to clear login attempts", "error", err)
+	}
+	if s := userSuspension(user); s.active(time.Now()) {
+		cfg.metrics.LoginFailed()
+		respondWithError(w, r, s.err())
 		return
 	}
-	exp := time.Duration(3600) * time.Second
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, exp)
+
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.jwtSecret, cfg.jwtLifetime)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create authentication token")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 
 	refresh_token, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Coludn't create refresh token")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 	cfg.database.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refresh_token,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		ExpiresAt: time.Now().Add(cfg.refreshTokenLifetime),
 	})
 
+	cfg.metrics.LoginSucceeded()
 	respondWithJSON(w, http.StatusOK, response{
 		ID:           user.ID,
 		CreatedAt:    user.CreatedAt,
 		UpdatedAt:    user.UpdatedAt,
 		Email:        user.Email,
 		IsChirpyRed:  user.IsChirpyRed.Bool,
+		Role:         user.Role,
 		Token:        token,
 		RefreshToken: refresh_token,
 	})
@@ -231,30 +432,23 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string `json:"password" validate:"required,min=8,maxbytes=72"`
+		Email    string `json:"email" validate:"required,email"`
 	}
 	type response struct {
 		User
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		log.Printf("Error decoding json: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
-		return
-	}
-	if !isValidEmail(params.Email) {
-		log.Printf("Invalid email: %s", params.Email)
-		respondWithError(w, http.StatusBadRequest, "Invalid Email format")
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
+	_, span := tracing.Tracer().Start(r.Context(), "auth.HashPassword")
 	hashedPassword, err := auth.HashPassword(params.Password)
+	span.End()
 	if err != nil {
-		log.Printf("failed to hash password %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 
@@ -263,26 +457,47 @@ func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 		HashedPassword: hashedPassword,
 	})
 	if err != nil {
-		log.Printf("failed to create user: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		respondWithError(w, r, fromUniqueViolation(err, "email", "Email is already registered"))
 		return
 	}
 
 	respondWithJSON(w, http.StatusCreated, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+		User: toUser(user),
 	})
 }
 
-func isValidEmail(email string) bool {
-	return strings.Contains(email, "@")
+// recordLoginFailure counts a failed login against the email and IP and
+// emails the account owner when the email gets locked.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, emailKey, ipKey string, user database.User, found bool) {
+	logger := loggerFrom(ctx)
+	locked, err := cfg.loginGuard.Fail(ctx, emailKey)
+	if err != nil {
+		logger.Error("Failed to record login failure", "error", err)
+	}
+	if _, err := cfg.loginGuard.Fail(ctx, ipKey); err != nil {
+		logger.Error("Failed to record login failure", "error", err)
+	}
+	if !locked || !found {
+		return
+	}
+	logger.Warn("Account locked after repeated failed logins", "user_id", user.ID)
+	err = cfg.sendEmail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account has been temporarily locked",
+		Body: "We noticed several failed attempts to log in to your Chirpy account, " +
+			"so we have temporarily locked it. If this was you, wait a few minutes and try again. " +
+			"If it wasn't, consider changing your password once you can log in.",
+	})
+	if err != nil {
+		logger.Error("Failed to queue lockout email", "error", err)
+	}
 }
 
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("chirpy-dummy-password")
+	return hash
+})
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		//increment the counter
@@ -299,39 +514,37 @@ func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Load())
 }
 
-func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
-	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Reset is only allowed in dev environment."))
+func (cfg *apiConfig) retrieveHandler(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.optionalViewer(r)
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-
-	cfg.fileserverHits.Store(0)
-	cfg.database.DeleteUsers(r.Context())
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Hits reset to 0 and database reset to initial state."))
-}
-
-func (cfg *apiConfig) retrieveHandler(w http.ResponseWriter, r *http.Request) {
 	var chirpsArray []database.Chirp
-	var err error
 	s := r.URL.Query().Get("author_id")
 	sorted := r.URL.Query().Get("sort")
 	if s == "" {
-		chirpsArray, err = cfg.database.RetrieveChirps(r.Context())
+		chirpsArray, err = cfg.database.RetrieveChirps(r.Context(), viewerID)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps")
+			respondWithError(w, r, problem.Internal(err))
 			return
 		}
 	} else {
-		parsedChirp, err := uuid.Parse(s)
+		author, err := cfg.findUser(r.Context(), s)
+		if problem.From(err).Code == problem.CodeNotFound {
+			respondWithJSON(w, http.StatusOK, []Chirp{})
+			return
+		}
 		if err != nil {
-			respondWithError(w, http.StatusNotFound, "Invalid chirpID format. Ensure it is a valid UUID")
+			respondWithError(w, r, err)
 			return
 		}
-		chirpsArray, err = cfg.database.RetrieveChirpsByAuthor(r.Context(), parsedChirp)
+		chirpsArray, err = cfg.database.RetrieveChirpsByAuthor(r.Context(), database.RetrieveChirpsByAuthorParams{
+			UserID:   author.ID,
+			ViewerID: viewerID,
+		})
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps")
+			respondWithError(w, r, problem.Internal(err))
 			return
 		}
 	}
@@ -339,13 +552,11 @@ func (cfg *apiConfig) retrieveHandler(w http.ResponseWriter, r *http.Request) {
 	//convert database struct to api struct (lower case field names)
 	chirpsToReturn := make([]Chirp, len(chirpsArray))
 	for i, c := range chirpsArray {
-		chirpsToReturn[i] = Chirp{
-			ID:        c.ID,
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			UserID:    c.UserID,
-		}
+		chirpsToReturn[i] = toChirp(c)
+	}
+	if err := cfg.decorateChirps(r.Context(), viewerID, chirpsToReturn); err != nil {
+		respondWithError(w, r, err)
+		return
 	}
 
 	if strings.ToLower(sorted) == "desc" {
@@ -356,130 +567,143 @@ func (cfg *apiConfig) retrieveHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) grabChirpHandler(w http.ResponseWriter, r *http.Request) {
-	chirpID := r.PathValue("chirpID")
-	if chirpID == "" {
-		respondWithError(w, http.StatusNotFound, "Chirp ID is missing in the request path")
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-	parsedChirp, err := uuid.Parse(chirpID)
+	viewerID, err := cfg.optionalViewer(r)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid chirpID format. Ensure it is a valid UUID")
+		respondWithError(w, r, err)
+		return
+	}
+	chirp, err := cfg.database.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Chirp not found"))
 		return
 	}
-	chirp, err := cfg.database.GrabChirp(r.Context(), parsedChirp)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
-	response := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	response := []Chirp{toChirp(chirp)}
+	if err := cfg.decorateChirps(r.Context(), viewerID, response); err != nil {
+		respondWithError(w, r, err)
+		return
 	}
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithJSON(w, http.StatusOK, response[0])
 }
 
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
-	type validate struct {
-		Body string `json:"body"`
+	type parameters struct {
+		Body      string     `json:"body" validate:"required"`
+		MediaIDs  []string   `json:"media_ids"`
+		PublishAt *time.Time `json:"publish_at"`
+		// QuotedChirpID makes the new chirp a quote of another chirp.
+		QuotedChirpID string `json:"quoted_chirp_id" validate:"uuid"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	val := validate{}
-	err := decoder.Decode(&val)
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusBadRequest, "Invalid Json")
+		respondWithError(w, r, err)
+		return
+	}
+
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-	if len(val.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	publishAt, err := parsePublishAt(params.PublishAt)
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-	if len(val.Body) == 0 {
-		respondWithError(w, http.StatusBadRequest, "Chirp body is empty")
+	mediaIDs, err := parseMediaIDs(params.MediaIDs)
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
-	token, err := auth.GetBearerToken(r.Header)
+	quoted, err := cfg.quoteTarget(r.Context(), userID, params.QuotedChirpID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondWithError(w, r, err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	body, status, err := cfg.screenChirp(r.Context(), params.Body)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondWithError(w, r, err)
 		return
 	}
-	cleanedText := filterProfanity(val.Body)
+	if publishAt.Valid && status == chirpPublished {
+		status = chirpScheduled
+	}
 
-	createdChirp, err := cfg.database.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedText,
-		UserID: userID,
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+	createdChirp, err := q.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:          body,
+		UserID:        userID,
+		Status:        status,
+		PublishAt:     publishAt,
+		QuotedChirpID: quoted,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create chirp")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
-	response := Chirp{
-		ID:        createdChirp.ID,
-		CreatedAt: createdChirp.CreatedAt,
-		UpdatedAt: createdChirp.UpdatedAt,
-		Body:      createdChirp.Body,
-		UserID:    createdChirp.UserID,
+	if err := attachMedia(r.Context(), q, createdChirp.ID, userID, mediaIDs); err != nil {
+		respondWithError(w, r, err)
+		return
 	}
-	respondWithJSON(w, http.StatusCreated, response)
-}
-
-func filterProfanity(chirp string) string {
-	bad_words := []string{"kerfuffle", "sharbert", "fornax"}
-	split := strings.Split(chirp, " ")
-	for i, word := range split {
-		lower_cased := strings.ToLower(word)
-		for _, bad_word := range bad_words {
-			if lower_cased == bad_word {
-				split[i] = "****"
-			}
+	if status == chirpScheduled {
+		if err := schedulePublish(r.Context(), q, createdChirp); err != nil {
+			respondWithError(w, r, problem.Internal(err))
+			return
 		}
 	}
-	joined := strings.Join(split, " ")
-	return joined
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	cfg.metrics.ChirpsCreated.Inc()
+	response := []Chirp{toChirp(createdChirp)}
+	if err := cfg.decorateChirps(r.Context(), userID, response); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response[0])
 }
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	type errorResponse struct {
-		Error string `json:"error"`
+// respondWithError writes err as an RFC 7807 problem. The cause of server
+// errors is logged but never sent to the client.
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem.From(err)
+	if p.Status >= http.StatusInternalServerError {
+		loggerFrom(r.Context()).Error("Request failed", "code", p.Code, "error", err)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-
-	errResp := errorResponse{Error: msg}
-	dat, _ := json.Marshal(errResp)
-	w.Write(dat)
+	problem.Write(w, r, p)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error marshalling JSON")
+		slog.Error("Error marshalling JSON", "error", err)
+		problem.Write(w, nil, problem.Internal(err))
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	w.Write(dat)
 }
 
-/*
-func middlewareLog(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
-}
-*/
-
 /*
 // Convert a database Chirp to an API Chirp
 func dbChirpToAPIChirp(dbChirp database.Chirp) Chirp {
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/metrics"
+	"github.com/iahta/chirpy/internal/moderation"
+	"github.com/iahta/chirpy/internal/testdb"
+)
+
+// testConfig returns an apiConfig backed by a freshly migrated schema of
+// the database in CHIRPY_TEST_DB_URL, or skips the test.
+func testConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db := testdb.Open(t, "chirpy_test")
+	q := database.New(db)
+	return &apiConfig{
+		db:         db,
+		database:   q,
+		metrics:    metrics.New(nil),
+		jwtSecret:  "test-secret",
+		moderation: moderation.NewFilter(q, time.Minute),
+	}
+}
+
+// testUser creates a user and returns its ID and an access token.
+func testUser(t *testing.T, cfg *apiConfig, email string) (uuid.UUID, string) {
+	t.Helper()
+	user, err := cfg.database.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.MakeJWT(user.ID, auth.RoleUser, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID, token
+}
+
+// serve calls handler as the holder of token, with pathValues set.
+func serve(handler http.HandlerFunc, method, token, body string, pathValues ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		req.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// testChirp publishes a chirp by userID, quoting quoted if it is not
+// uuid.Nil.
+func testChirp(t *testing.T, cfg *apiConfig, userID uuid.UUID, body string, quoted uuid.UUID) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.database.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:          body,
+		UserID:        userID,
+		Status:        chirpPublished,
+		QuotedChirpID: uuid.NullUUID{UUID: quoted, Valid: quoted != uuid.Nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chirp
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/blob"
+	"github.com/iahta/chirpy/internal/config"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/media"
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+// Media is an uploaded image. Its URLs are relative to the API's origin.
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ContentType  string    `json:"content_type"`
+	Size         int       `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+const (
+	mediaAttachment = "attachment"
+	mediaAvatar     = "avatar"
+
+	mediaOriginal  = "original"
+	mediaThumbnail = "thumbnail"
+
+	// maxChirpMedia is how many images one chirp may carry.
+	maxChirpMedia = 4
+	// unattachedMediaTTL is how long an upload may wait to be attached to
+	// a chirp before it is deleted.
+	unattachedMediaTTL = 24 * time.Hour
+)
+
+func toMedia(m database.Media) Media {
+	url := "/api/media/" + m.ID.String()
+	return Media{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt,
+		ContentType:  m.ContentType,
+		Size:         int(m.SizeBytes),
+		Width:        int(m.Width),
+		Height:       int(m.Height),
+		URL:          url,
+		ThumbnailURL: url + "/thumbnail",
+	}
+}
+
+func mediaKey(id uuid.UUID, variant string) string {
+	return "media/" + id.String() + "/" + variant
+}
+
+func newBlobStore(cfg config.MediaConfig) blob.Store {
+	if cfg.Store == "s3" {
+		return &blob.S3Store{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		}
+	}
+	return blob.LocalStore{Dir: cfg.Dir}
+}
+
+// readUpload returns the contents of the file field of a multipart form,
+// refusing files over maxBytes without buffering them.
+func readUpload(w http.ResponseWriter, r *http.Request, maxBytes int) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"Content-Type must be multipart/form-data")
+	}
+	tooLarge := &problem.Error{
+		Status: http.StatusRequestEntityTooLarge,
+		Code:   problem.CodePayloadTooLarge,
+		Detail: fmt.Sprintf("File must be at most %d bytes", maxBytes),
+	}
+	// Leave room for the multipart headers and any small fields.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes)+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, invalidForm(err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, problem.Validation(problem.FieldError{Field: "file", Code: "required", Message: "is required"})
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, tooLarge
+		}
+		if err != nil {
+			return nil, invalidForm(err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, int64(maxBytes)+1))
+		if errors.As(err, &maxErr) || len(data) > maxBytes {
+			return nil, tooLarge
+		}
+		if err != nil {
+			return nil, invalidForm(err)
+		}
+		return data, nil
+	}
+}
+
+func invalidForm(err error) error {
+	return &problem.Error{
+		Status: http.StatusBadRequest,
+		Code:   problem.CodeInvalidJSON,
+		Detail: "Request body is not a valid multipart form",
+		Err:    err,
+	}
+}
+
+// storeUpload processes an uploaded image and saves it and its thumbnail.
+func (cfg *apiConfig) storeUpload(ctx context.Context, userID uuid.UUID, kind string, data []byte) (database.Media, error) {
+	img, err := media.Process(data, cfg.mediaLimits)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		return database.Media{}, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"File must be a JPEG, PNG or GIF image")
+	case errors.Is(err, media.ErrCorrupt):
+		return database.Media{}, problem.Validation(problem.FieldError{Field: "file", Code: "invalid_image", Message: "could not be read as an image"})
+	case errors.Is(err, media.ErrTooLarge):
+		return database.Media{}, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+			fmt.Sprintf("Image must be at most %d pixels", cfg.mediaLimits.MaxPixels))
+	case err != nil:
+		return database.Media{}, problem.Internal(err)
+	}
+
+	id := uuid.New()
+	if err := cfg.blobs.Put(ctx, mediaKey(id, mediaOriginal), img.ContentType, img.Data); err != nil {
+		return database.Media{}, problem.Internal(err)
+	}
+	if err := cfg.blobs.Put(ctx, mediaKey(id, mediaThumbnail), img.ThumbnailType, img.Thumbnail); err != nil {
+		cfg.deleteMediaBlobs(ctx, id)
+		return database.Media{}, problem.Internal(err)
+	}
+	m, err := cfg.database.CreateMedia(ctx, database.CreateMediaParams{
+		ID:          id,
+		UserID:      userID,
+		Kind:        kind,
+		ContentType: img.ContentType,
+		SizeBytes:   int32(len(img.Data)),
+		Width:       int32(img.Width),
+		Height:      int32(img.Height),
+	})
+	if err != nil {
+		cfg.deleteMediaBlobs(ctx, id)
+		return database.Media{}, problem.Internal(err)
+	}
+	return m, nil
+}
+
+// deleteMediaBlobs removes both variants of a media item, logging rather
+// than returning failures since the blobs are unreachable either way.
+func (cfg *apiConfig) deleteMediaBlobs(ctx context.Context, id uuid.UUID) {
+	for _, variant := range []string{mediaOriginal, mediaThumbnail} {
+		if err := cfg.blobs.Delete(ctx, mediaKey(id, variant)); err != nil {
+			loggerFrom(ctx).Warn("Failed to delete media blob", "media_id", id, "variant", variant, "error", err)
+		}
+	}
+}
+
+// uploadMediaHandler stores an image to be attached to a chirp through
+// its media_ids. Images not attached within a day are deleted.
+func (cfg *apiConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	data, err := readUpload(w, r, cfg.maxUploadBytes)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	m, err := cfg.storeUpload(r.Context(), userID, mediaAttachment, data)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toMedia(m))
+}
+
+// uploadAvatarHandler stores an image and makes its thumbnail the user's
+// avatar, deleting the previous avatar.
+func (cfg *apiConfig) uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	data, err := readUpload(w, r, cfg.maxUploadBytes)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	m, err := cfg.storeUpload(r.Context(), userID, mediaAvatar, data)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	user, err := cfg.database.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		AvatarUrl: sql.NullString{String: toMedia(m).ThumbnailURL, Valid: true},
+		ID:        userID,
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	old, err := cfg.database.DeleteOtherAvatars(r.Context(), database.DeleteOtherAvatarsParams{UserID: userID, ID: m.ID})
+	if err != nil {
+		loggerFrom(r.Context()).Warn("Failed to delete previous avatars", "error", err)
+	}
+	for _, id := range old {
+		cfg.deleteMediaBlobs(r.Context(), id)
+	}
+	respondWithJSON(w, http.StatusOK, toProfile(user))
+}
+
+func (cfg *apiConfig) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, mediaOriginal)
+}
+
+func (cfg *apiConfig) serveThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, mediaThumbnail)
+}
+
+// serveMedia streams a stored image. Images attached to chirps that are
+// held or hidden are not served.
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, variant string) {
+	mediaID, err := pathUUID(r, "mediaID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	m, err := cfg.database.GetMedia(r.Context(), mediaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Media not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if m.ChirpID.Valid {
+		chirp, err := cfg.database.GrabChirp(r.Context(), m.ChirpID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, problem.Internal(err))
+			return
+		}
+		if err != nil || chirp.Status != chirpPublished {
+			respondWithError(w, r, problem.NotFound("Media not found"))
+			return
+		}
+	}
+
+	body, contentType, err := cfg.blobs.Get(r.Context(), mediaKey(mediaID, variant))
+	if errors.Is(err, blob.ErrNotFound) {
+		respondWithError(w, r, problem.NotFound("Media not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, body)
+}
+
+// parseMediaIDs checks the media_ids of a new chirp.
+func parseMediaIDs(raw []string) ([]uuid.UUID, error) {
+	if len(raw) > maxChirpMedia {
+		return nil, problem.Validation(problem.FieldError{
+			Field:   "media_ids",
+			Code:    "too_many",
+			Message: fmt.Sprintf("must contain at most %d items", maxChirpMedia),
+		})
+	}
+	ids := make([]uuid.UUID, 0, len(raw))
+	var fields []problem.FieldError
+	seen := map[uuid.UUID]bool{}
+	for i, s := range raw {
+		id, err := uuid.Parse(s)
+		field := fmt.Sprintf("media_ids[%d]", i)
+		switch {
+		case err != nil:
+			fields = append(fields, problem.FieldError{Field: field, Code: "invalid_uuid", Message: "must be a UUID"})
+		case seen[id]:
+			fields = append(fields, problem.FieldError{Field: field, Code: "duplicate", Message: "is listed more than once"})
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(fields) > 0 {
+		return nil, problem.Validation(fields...)
+	}
+	return ids, nil
+}
+
+// attachMedia attaches the user's unattached uploads to a new chirp in
+// order.
+func attachMedia(ctx context.Context, q *database.Queries, chirpID, userID uuid.UUID, ids []uuid.UUID) error {
+	for i, id := range ids {
+		n, err := q.AttachMedia(ctx, database.AttachMediaParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpID, Valid: true},
+			Position: int32(i),
+			ID:       id,
+			UserID:   userID,
+		})
+		if err != nil {
+			return problem.Internal(err)
+		}
+		if n == 0 {
+			return problem.Validation(problem.FieldError{
+				Field:   fmt.Sprintf("media_ids[%d]", i),
+				Code:    "unavailable",
+				Message: "must be one of your uploads not yet attached to a chirp",
+			})
+		}
+	}
+	return nil
+}
+
+// withMedia fills in the media of each chirp.
+func (cfg *apiConfig) withMedia(ctx context.Context, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	rows, err := cfg.database.ListMediaForChirps(ctx, ids)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	byChirp := make(map[uuid.UUID][]Media)
+	for _, m := range rows {
+		byChirp[m.ChirpID.UUID] = append(byChirp[m.ChirpID.UUID], toMedia(m))
+	}
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// purgeUnattachedMediaJob deletes uploads that were never attached to a
+// chirp or whose chirp was deleted.
+func (cfg *apiConfig) purgeUnattachedMediaJob(ctx context.Context, job database.Job) error {
+	ids, err := cfg.database.ListUnattachedMedia(ctx, time.Now().UTC().Add(-unattachedMediaTTL))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		cfg.deleteMediaBlobs(ctx, id)
+		if err := cfg.database.DeleteMedia(ctx, id); err != nil {
+			return err
+		}
+	}
+	slog.Info("Purged unattached media", "count", len(ids))
+	return cfg.scheduleDaily(ctx, job.Kind)
+}
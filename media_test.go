@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func multipartRequest(t *testing.T, field string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, "upload.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/media", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestReadUpload(t *testing.T) {
+	testCases := []struct {
+		name     string
+		req      *http.Request
+		wantCode problem.Code
+	}{
+		{"ok", multipartRequest(t, "file", []byte("0123456789")), ""},
+		{"too large", multipartRequest(t, "file", bytes.Repeat([]byte("x"), 11)), problem.CodePayloadTooLarge},
+		{"missing file", multipartRequest(t, "other", []byte("x")), problem.CodeValidationFailed},
+		{"not multipart", httptest.NewRequest(http.MethodPost, "/api/media", strings.NewReader("{}")), problem.CodeUnsupportedMediaType},
+	}
+	for _, tc := range testCases {
+		data, err := readUpload(httptest.NewRecorder(), tc.req, 10)
+		if tc.wantCode == "" {
+			if err != nil || string(data) != "0123456789" {
+				t.Errorf("%s: got %q, %v", tc.name, data, err)
+			}
+			continue
+		}
+		if code := problem.From(err).Code; code != tc.wantCode {
+			t.Errorf("%s: code = %s, expected %s", tc.name, code, tc.wantCode)
+		}
+	}
+}
+
+func TestParseMediaIDs(t *testing.T) {
+	const id = "6f1c2a44-3a59-4c39-9fb4-8d5b0a8e2c11"
+	if ids, err := parseMediaIDs([]string{id}); err != nil || len(ids) != 1 {
+		t.Errorf("valid IDs: %v, %v", ids, err)
+	}
+	for name, raw := range map[string][]string{
+		"too many":  {id, id, id, id, id},
+		"not uuid":  {"nope"},
+		"duplicate": {id, id},
+	} {
+		if _, err := parseMediaIDs(raw); problem.From(err).Code != problem.CodeValidationFailed {
+			t.Errorf("%s: err = %v, expected a validation problem", name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	var seen string
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = w.Header().Get(requestIDHeader)
+		if loggerFrom(r.Context()) == nil {
+			t.Errorf("expected a request-scoped logger")
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}), middlewareRequestID, middlewareLog)
+
+	testCases := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"propagates valid id", "abc-123", true},
+		{"replaces missing id", "", false},
+		{"replaces id with spaces", "not valid", false},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+		if tc.incoming != "" {
+			req.Header.Set(requestIDHeader, tc.incoming)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(requestIDHeader)
+		if got == "" || got != seen {
+			t.Errorf("%s: response id %q does not match handler id %q", tc.name, got, seen)
+		}
+		if tc.keep != (got == tc.incoming) {
+			t.Errorf("%s: got id %q for incoming %q", tc.name, got, tc.incoming)
+		}
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("%s: status = %d, expected %d", tc.name, rec.Code, http.StatusTeapot)
+		}
+	}
+}
+
+func TestMiddlewareTracingNamesSpanAfterRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {})
+	handler := chain(mux, middlewareRequestID, middlewareTracing(mux), middlewareLog)
+
+	testCases := []struct {
+		path  string
+		name  string
+		route string
+	}{
+		{"/api/chirps/123", "GET /api/chirps/{chirpID}", "GET /api/chirps/{chirpID}"},
+		{"/api/nope", "GET", ""},
+	}
+	for _, tc := range testCases {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		if span.Name() != tc.name {
+			t.Errorf("%s: span name = %q, expected %q", tc.path, span.Name(), tc.name)
+		}
+		var route string
+		for _, attr := range span.Attributes() {
+			if attr.Key == semconv.HTTPRouteKey {
+				route = attr.Value.AsString()
+			}
+		}
+		if route != tc.route {
+			t.Errorf("%s: http.route = %q, expected %q", tc.path, route, tc.route)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iahta/chirpy/internal/config"
+	"github.com/iahta/chirpy/sql/schema"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: chirpy migrate [flags] up|down|status|redo"
+
+// runMigrate implements the migrate subcommand: up applies all pending
+// migrations, down rolls back the latest one, redo rolls it back and
+// applies it again, and status lists every migration.
+func runMigrate(ctx context.Context, cfg config.Config, w io.Writer) error {
+	if len(cfg.Args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	switch cfg.Args[0] {
+	case "up", "down", "redo", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", cfg.Args[0], migrateUsage)
+	}
+	if cfg.DatabaseURL == "" {
+		return errors.New("DB_URL must be set")
+	}
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	defer db.Close()
+	provider, err := schema.NewProvider(db)
+	if err != nil {
+		return err
+	}
+
+	var results []*goose.MigrationResult
+	switch cfg.Args[0] {
+	case "up":
+		results, err = provider.Up(ctx)
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(w, "No migrations to apply")
+		}
+	case "down":
+		var res *goose.MigrationResult
+		res, err = provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(w, "No migrations to roll back")
+			return nil
+		}
+		results = append(results, res)
+	case "redo":
+		var down, up *goose.MigrationResult
+		if down, err = provider.Down(ctx); err == nil {
+			up, err = provider.UpByOne(ctx)
+		}
+		results = append(results, down, up)
+	case "status":
+		return printMigrationStatus(ctx, provider, w)
+	}
+	for _, res := range results {
+		if res != nil {
+			fmt.Fprintln(w, res)
+		}
+	}
+	return err
+}
+
+func printMigrationStatus(ctx context.Context, provider *goose.Provider, w io.Writer) error {
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+	}
+	return tw.Flush()
+}
+
+// migrateOnStart applies pending migrations before the server starts,
+// for deployments that set migrate_on_start.
+func migrateOnStart(ctx context.Context, db *sql.DB) error {
+	provider, err := schema.NewProvider(db)
+	if err != nil {
+		return err
+	}
+	results, err := provider.Up(ctx)
+	for _, res := range results {
+		slog.Info("Applied migration", "version", res.Source.Version, "source", res.Source.Path, "duration", res.Duration)
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/chirptext"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/moderation"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+)
+
+// moderationReloadInterval bounds how long a rule change made through
+// another instance takes to apply here.
+const moderationReloadInterval = 30 * time.Second
+
+type ModerationRule struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+}
+
+type ruleParameters struct {
+	Kind    string `json:"kind" validate:"required"`
+	Pattern string `json:"pattern" validate:"required,maxbytes=1000"`
+	Action  string `json:"action" validate:"required"`
+}
+
+// decodeRule reads a rule from the request body and checks that it
+// compiles.
+func decodeRule(w http.ResponseWriter, r *http.Request) (moderation.Rule, error) {
+	params := ruleParameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		return moderation.Rule{}, err
+	}
+	rule := moderation.Rule{
+		Kind:    moderation.Kind(params.Kind),
+		Pattern: params.Pattern,
+		Action:  moderation.Action(params.Action),
+	}
+	var fields []problem.FieldError
+	switch rule.Kind {
+	case moderation.KindWord, moderation.KindRegex:
+	default:
+		fields = append(fields, problem.FieldError{Field: "kind", Code: "invalid_choice", Message: "must be word or regex"})
+	}
+	switch rule.Action {
+	case moderation.ActionMask, moderation.ActionHold, moderation.ActionReject:
+	default:
+		fields = append(fields, problem.FieldError{Field: "action", Code: "invalid_choice", Message: "must be mask, hold or reject"})
+	}
+	if len(fields) == 0 {
+		if err := rule.Check(); err != nil {
+			fields = append(fields, problem.FieldError{Field: "pattern", Code: "invalid_pattern", Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return moderation.Rule{}, problem.Validation(fields...)
+	}
+	return rule, nil
+}
+
+func toModerationRule(r database.ModerationRule) ModerationRule {
+	return ModerationRule(r)
+}
+
+func ruleIDs(rules []moderation.Rule) []string {
+	ids := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = r.ID.String()
+	}
+	return ids
+}
+
+func (cfg *apiConfig) listRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := cfg.database.ListModerationRules(r.Context())
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	response := make([]ModerationRule, len(rules))
+	for i, rule := range rules {
+		response[i] = toModerationRule(rule)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) createRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule, err := decodeRule(w, r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	created, err := cfg.database.CreateModerationRule(r.Context(), database.CreateModerationRuleParams{
+		Kind:    string(rule.Kind),
+		Pattern: rule.Pattern,
+		Action:  string(rule.Action),
+	})
+	if err != nil {
+		respondWithError(w, r, fromUniqueViolation(err, "pattern", "A rule with this pattern already exists"))
+		return
+	}
+	cfg.moderation.Invalidate()
+	loggerFrom(r.Context()).Info("Moderation rule created", "rule_id", created.ID, "kind", created.Kind, "action", created.Action)
+	respondWithJSON(w, http.StatusCreated, toModerationRule(created))
+}
+
+func (cfg *apiConfig) updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := pathUUID(r, "ruleID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rule, err := decodeRule(w, r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	updated, err := cfg.database.UpdateModerationRule(r.Context(), database.UpdateModerationRuleParams{
+		ID:      ruleID,
+		Kind:    string(rule.Kind),
+		Pattern: rule.Pattern,
+		Action:  string(rule.Action),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Moderation rule not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, fromUniqueViolation(err, "pattern", "A rule with this pattern already exists"))
+		return
+	}
+	cfg.moderation.Invalidate()
+	loggerFrom(r.Context()).Info("Moderation rule updated", "rule_id", updated.ID, "kind", updated.Kind, "action", updated.Action)
+	respondWithJSON(w, http.StatusOK, toModerationRule(updated))
+}
+
+func (cfg *apiConfig) deleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := pathUUID(r, "ruleID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	n, err := cfg.database.DeleteModerationRule(r.Context(), ruleID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if n == 0 {
+		respondWithError(w, r, problem.NotFound("Moderation rule not found"))
+		return
+	}
+	cfg.moderation.Invalidate()
+	loggerFrom(r.Context()).Info("Moderation rule deleted", "rule_id", ruleID)
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// testRulesHandler shows what the current rules would do to a chirp
+// without posting it.
+func (cfg *apiConfig) testRulesHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body" validate:"required"`
+	}
+	type response struct {
+		Body    string      `json:"body"`
+		Action  string      `json:"action"`
+		Matched []uuid.UUID `json:"matched"`
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	cfg.moderation.Invalidate()
+	engine, err := cfg.moderation.Engine(r.Context())
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	screened := engine.Apply(chirptext.Normalize(params.Body))
+	resp := response{Body: screened.Text, Action: string(screened.Action), Matched: []uuid.UUID{}}
+	if resp.Action == "" {
+		resp.Action = "allow"
+	}
+	for _, m := range screened.Matched {
+		resp.Matched = append(resp.Matched, m.ID)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/lib/pq"
+)
+
+var (
+	errInvalidCredentials = problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect email or password")
+)
+
+// pathUUID parses the named path value, reporting a validation problem if
+// it is not a UUID.
+func pathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.Nil, problem.Validation(problem.FieldError{
+			Field:   name,
+			Code:    "invalid_uuid",
+			Message: "must be a UUID",
+		})
+	}
+	return id, nil
+}
+
+// invalidRefreshToken reports an unknown, expired or revoked refresh token.
+// Lookup failures other than a missing row are server errors.
+func invalidRefreshToken(err error) error {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return problem.Internal(err)
+	}
+	return problem.Unauthorized("Invalid or expired refresh token")
+}
+
+// fromUniqueViolation turns a unique constraint violation into a conflict
+// on field. Other errors are server errors.
+func fromUniqueViolation(err error, field, detail string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return &problem.Error{
+			Status: http.StatusConflict,
+			Code:   problem.CodeConflict,
+			Detail: detail,
+			Fields: []problem.FieldError{{Field: field, Code: "taken", Message: detail}},
+			Err:    err,
+		}
+	}
+	return problem.Internal(err)
+}
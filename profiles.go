@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/handle"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+)
+
+// Profile is the public view of a user. It never includes the email.
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func toProfile(u database.User) Profile {
+	return Profile{
+		ID:          u.ID,
+		Handle:      nullString(u.Handle),
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarUrl,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// findUser looks a user up by ID or, failing that, by handle. Banned users
+// are reported as missing.
+func (cfg *apiConfig) findUser(ctx context.Context, ident string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ident); parseErr == nil {
+		user, err = cfg.database.GetUserById(ctx, id)
+	} else {
+		user, err = cfg.database.GetUserByHandle(ctx, handle.Trim(ident))
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && userSuspension(user).banned()) {
+		return database.User{}, problem.NotFound("User not found")
+	}
+	if err != nil {
+		return database.User{}, problem.Internal(err)
+	}
+	return user, nil
+}
+
+func (cfg *apiConfig) profileHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.findUser(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toProfile(user))
+}
+
+// updateProfileHandler changes the fields present in the request and
+// leaves the rest alone. An empty display_name, bio or avatar_url clears
+// it; a handle cannot be removed once set.
+func (cfg *apiConfig) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name" validate:"max=50"`
+		Bio         *string `json:"bio" validate:"max=160"`
+		AvatarURL   *string `json:"avatar_url" validate:"url,maxbytes=2048"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	arg := database.UpdateUserProfileParams{
+		DisplayName: optionalString(params.DisplayName),
+		Bio:         optionalString(params.Bio),
+		AvatarUrl:   optionalString(params.AvatarURL),
+		ID:          userID,
+	}
+	if params.Handle != nil {
+		h := handle.Trim(*params.Handle)
+		if err := handle.Validate(h); err != nil {
+			code := "invalid_handle"
+			if errors.Is(err, handle.ErrReserved) {
+				code = "reserved"
+			}
+			respondWithError(w, r, problem.Validation(problem.FieldError{
+				Field:   "handle",
+				Code:    code,
+				Message: err.Error(),
+			}))
+			return
+		}
+		arg.Handle = sql.NullString{String: h, Valid: true}
+	}
+
+	user, err := cfg.database.UpdateUserProfile(r.Context(), arg)
+	if err != nil {
+		respondWithError(w, r, fromUniqueViolation(err, "handle", "Handle is already taken"))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toProfile(user))
+}
+
+// optionalString maps an absent JSON field to NULL, which the profile
+// update leaves unchanged.
+func optionalString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/config"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/ratelimit"
+)
+
+// middlewareRateLimit applies the limit configured for the route mux would
+// dispatch r to. Clients with a valid access token are limited per user,
+// with Chirpy Red quotas where configured; everyone else is limited per IP.
+// If the store fails the request is let through.
+func (cfg *apiConfig) middlewareRateLimit(mux *http.ServeMux, store ratelimit.Store, limits config.RateLimitConfig) middleware {
+	redCache := &redStatusCache{entries: map[uuid.UUID]redStatusEntry{}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			routeLimit, ok := limits.Routes[pattern]
+			if !ok {
+				routeLimit = limits.Default
+			}
+
+			client := "ip:" + clientIP(r, limits.TrustProxy)
+			requests := routeLimit.Requests
+			if userID, ok := cfg.bearerUser(r); ok {
+				client = "user:" + userID.String()
+				if routeLimit.RedRequests > 0 && redCache.isRed(r.Context(), cfg, userID) {
+					requests = routeLimit.RedRequests
+				}
+			}
+
+			res, err := store.Take(r.Context(), pattern+"|"+client, ratelimit.Limit{
+				Requests: requests,
+				Per:      routeLimit.Per,
+			})
+			if err != nil {
+				loggerFrom(r.Context()).Warn("Rate limit store failed, allowing request", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			ratelimit.SetHeaders(w.Header(), res)
+			if !res.Allowed {
+				respondWithError(w, r, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "Too many requests"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerUser returns the user from a valid access token, if there is one.
+func (cfg *apiConfig) bearerUser(r *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// clientIP identifies the client for rate limits and lockouts. Behind a
+// trusted proxy it is the rightmost X-Forwarded-For entry, the one the
+// proxy appended; entries to its left come from the client and can be
+// forged.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			last := fwd[len(fwd)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// redStatusCache avoids a database lookup on every authenticated request.
+// Upgrades take up to redStatusTTL to raise a user's quota.
+type redStatusCache struct {
+	mu        sync.Mutex
+	entries   map[uuid.UUID]redStatusEntry
+	lastSweep time.Time
+}
+
+type redStatusEntry struct {
+	red     bool
+	expires time.Time
+}
+
+const redStatusTTL = time.Minute
+
+func (c *redStatusCache) isRed(ctx context.Context, cfg *apiConfig, userID uuid.UUID) bool {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.red
+	}
+
+	red, err := cfg.database.IsUserChirpyRed(ctx, userID)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) > redStatusTTL {
+		c.lastSweep = now
+		for id, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[userID] = redStatusEntry{red: red.Bool, expires: now.Add(redStatusTTL)}
+	return red.Bool
+}
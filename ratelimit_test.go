@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		forwarded  []string
+		trustProxy bool
+		expected   string
+	}{
+		{"connection address", nil, false, "192.0.2.1"},
+		{"ignores header without trust", []string{"203.0.113.9"}, false, "192.0.2.1"},
+		{"single proxy entry", []string{"203.0.113.9"}, true, "203.0.113.9"},
+		{"spoofed prefix", []string{"10.9.9.9, 198.51.100.7, 203.0.113.9"}, true, "203.0.113.9"},
+		{"last header line", []string{"10.9.9.9", "203.0.113.9"}, true, "203.0.113.9"},
+		{"empty entry", []string{"10.9.9.9, "}, true, "192.0.2.1"},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/api/chirps", nil)
+		req.RemoteAddr = "192.0.2.1:4321"
+		for _, v := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(req, tc.trustProxy); got != tc.expected {
+			t.Errorf("%s: clientIP = %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/lib/pq"
+)
+
+// Relation is an entry in a user's block or mute list. Blocks hide chirps
+// in both directions between the two users; mutes only hide the muted
+// user's chirps from the muter. Both are applied by the chirp queries
+// through their viewer_id argument.
+type Relation struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationTarget authenticates the request and reads the other user from
+// the path, refusing to relate a user to themselves.
+func (cfg *apiConfig) relationTarget(r *http.Request) (userID, targetID uuid.UUID, err error) {
+	userID, err = cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	targetID, err = pathUUID(r, "userID")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if targetID == userID {
+		return uuid.Nil, uuid.Nil, problem.Validation(problem.FieldError{
+			Field:   "userID",
+			Code:    "self_reference",
+			Message: "must be another user",
+		})
+	}
+	return userID, targetID, nil
+}
+
+// fromMissingUser reports a foreign key violation as the target user not
+// existing.
+func fromMissingUser(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return problem.NotFound("User not found")
+	}
+	return problem.Internal(err)
+}
+
+func (cfg *apiConfig) relate(w http.ResponseWriter, r *http.Request, create func(ctx context.Context, userID, targetID uuid.UUID) error) {
+	userID, targetID, err := cfg.relationTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := create(r.Context(), userID, targetID); err != nil {
+		respondWithError(w, r, fromMissingUser(err))
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (cfg *apiConfig) unrelate(w http.ResponseWriter, r *http.Request, remove func(ctx context.Context, userID, targetID uuid.UUID) (int64, error), notFound string) {
+	userID, targetID, err := cfg.relationTarget(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	n, err := remove(r.Context(), userID, targetID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if n == 0 {
+		respondWithError(w, r, problem.NotFound(notFound))
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (cfg *apiConfig) blockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.relate(w, r, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		return cfg.database.CreateBlock(ctx, database.CreateBlockParams{BlockerID: userID, BlockedID: targetID})
+	})
+}
+
+func (cfg *apiConfig) unblockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.unrelate(w, r, func(ctx context.Context, userID, targetID uuid.UUID) (int64, error) {
+		return cfg.database.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: userID, BlockedID: targetID})
+	}, "User is not blocked")
+}
+
+func (cfg *apiConfig) muteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.relate(w, r, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		return cfg.database.CreateMute(ctx, database.CreateMuteParams{MuterID: userID, MutedID: targetID})
+	})
+}
+
+func (cfg *apiConfig) unmuteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.unrelate(w, r, func(ctx context.Context, userID, targetID uuid.UUID) (int64, error) {
+		return cfg.database.DeleteMute(ctx, database.DeleteMuteParams{MuterID: userID, MutedID: targetID})
+	}, "User is not muted")
+}
+
+func (cfg *apiConfig) listBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rows, err := cfg.database.ListBlocks(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	response := make([]Relation, len(rows))
+	for i, row := range rows {
+		response[i] = Relation{UserID: row.BlockedID, CreatedAt: row.CreatedAt}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) listMutesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rows, err := cfg.database.ListMutes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	response := make([]Relation, len(rows))
+	for i, row := range rows {
+		response[i] = Relation{UserID: row.MutedID, CreatedAt: row.CreatedAt}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
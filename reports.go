@@ -0,0 +1,330 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+const (
+	actionDismiss   = "dismiss"
+	actionHide      = "hide"
+	actionSuspend   = "suspend"
+	actionUnsuspend = "unsuspend"
+)
+
+type Report struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note"`
+}
+
+type QueueItem struct {
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Body        string    `json:"body"`
+	Status      string    `json:"status"`
+	ReportCount int64     `json:"report_count"`
+	Reasons     []string  `json:"reasons"`
+	QueuedAt    time.Time `json:"queued_at"`
+}
+
+type ModerationAction struct {
+	ID              uuid.UUID  `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ModeratorID     *uuid.UUID `json:"moderator_id"`
+	Action          string     `json:"action"`
+	ChirpID         *uuid.UUID `json:"chirp_id"`
+	UserID          *uuid.UUID `json:"user_id"`
+	Reason          string     `json:"reason"`
+	ReportsResolved int32      `json:"reports_resolved"`
+}
+
+func nullUUID(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func toModerationAction(a database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:              a.ID,
+		CreatedAt:       a.CreatedAt,
+		ModeratorID:     nullUUID(a.ModeratorID),
+		Action:          a.Action,
+		ChirpID:         nullUUID(a.ChirpID),
+		UserID:          nullUUID(a.UserID),
+		Reason:          a.Reason,
+		ReportsResolved: a.ReportsResolved,
+	}
+}
+
+// queryLimit reads the limit query parameter, defaulting to def and
+// capped at max.
+func queryLimit(r *http.Request, def, max int32) (int32, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || n < 1 || n > int64(max) {
+		return 0, problem.Validation(problem.FieldError{
+			Field:   "limit",
+			Code:    "out_of_range",
+			Message: fmt.Sprintf("must be between 1 and %d", max),
+		})
+	}
+	return int32(n), nil
+}
+
+func (cfg *apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason" validate:"required"`
+		Note   string `json:"note" validate:"max=500"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, r, problem.Validation(problem.FieldError{
+			Field:   "reason",
+			Code:    "invalid_choice",
+			Message: "must be one of " + strings.Join(reportReasons, ", "),
+		}))
+		return
+	}
+
+	chirp, err := cfg.database.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Chirp not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, r, problem.Forbidden("You cannot report your own chirp"))
+		return
+	}
+	report, err := cfg.database.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Note:       strings.TrimSpace(params.Note),
+	})
+	if err != nil {
+		respondWithError(w, r, fromUniqueViolation(err, "chirp_id", "You have already reported this chirp"))
+		return
+	}
+	loggerFrom(r.Context()).Info("Chirp reported", "chirp_id", chirpID, "report_id", report.ID, "reason", report.Reason)
+	respondWithJSON(w, http.StatusCreated, Report{
+		ID:        report.ID,
+		CreatedAt: report.CreatedAt,
+		ChirpID:   report.ChirpID,
+		Reason:    report.Reason,
+		Note:      report.Note,
+	})
+}
+
+// moderationQueueHandler lists chirps that are held by a moderation rule
+// or have open reports, oldest first.
+func (cfg *apiConfig) moderationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r, 50, 200)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rows, err := cfg.database.ListModerationQueue(r.Context(), limit)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	items := make([]QueueItem, len(rows))
+	for i, row := range rows {
+		items[i] = QueueItem{
+			ChirpID:     row.ID,
+			UserID:      row.UserID,
+			CreatedAt:   row.CreatedAt,
+			Body:        row.Body,
+			Status:      row.Status,
+			ReportCount: row.ReportCount,
+			Reasons:     row.Reasons,
+			QueuedAt:    row.QueuedAt,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// dismissedStatus is the status a chirp returns to when its reports are
+// dismissed. A chirp whose publish_at is still ahead goes back on the
+// schedule rather than being published early.
+func dismissedStatus(chirp database.Chirp, now time.Time) string {
+	if chirp.PublishAt.Valid && chirp.PublishAt.Time.After(now) {
+		return chirpScheduled
+	}
+	return chirpPublished
+}
+
+// moderateChirpHandler applies a moderator's decision to a chirp, resolves
+// its open reports and records the decision in the audit trail.
+func (cfg *apiConfig) moderateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action       string     `json:"action" validate:"required"`
+		Reason       string     `json:"reason" validate:"max=500"`
+		SuspendUntil *time.Time `json:"suspend_until"`
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	var fields []problem.FieldError
+	switch params.Action {
+	case actionDismiss, actionHide, actionSuspend:
+	default:
+		fields = append(fields, problem.FieldError{Field: "action", Code: "invalid_choice", Message: "must be dismiss, hide or suspend"})
+	}
+	if params.SuspendUntil != nil {
+		if params.Action != actionSuspend {
+			fields = append(fields, problem.FieldError{Field: "suspend_until", Code: "not_allowed", Message: "only applies to the suspend action"})
+		} else if !params.SuspendUntil.After(time.Now()) {
+			fields = append(fields, problem.FieldError{Field: "suspend_until", Code: "out_of_range", Message: "must be in the future"})
+		}
+	}
+	if len(fields) > 0 {
+		respondWithError(w, r, problem.Validation(fields...))
+		return
+	}
+	if params.SuspendUntil != nil {
+		// suspended_until is a TIMESTAMP, which would drop the offset.
+		until := params.SuspendUntil.UTC()
+		params.SuspendUntil = &until
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+
+	chirp, err := q.GrabChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Chirp not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+
+	status, resolution := chirpHidden, "actioned"
+	if params.Action == actionDismiss {
+		status, resolution = dismissedStatus(chirp, time.Now()), "dismissed"
+	}
+	if chirp.Status != status {
+		if err := q.SetChirpStatus(r.Context(), database.SetChirpStatusParams{ID: chirpID, Status: status}); err != nil {
+			respondWithError(w, r, problem.Internal(err))
+			return
+		}
+		if status == chirpScheduled {
+			if err := schedulePublish(r.Context(), q, chirp); err != nil {
+				respondWithError(w, r, problem.Internal(err))
+				return
+			}
+		}
+	}
+	if params.Action == actionSuspend {
+		if err := suspendUser(r.Context(), q, chirp.UserID, params.SuspendUntil, params.Reason); err != nil {
+			respondWithError(w, r, problem.Internal(err))
+			return
+		}
+	}
+	resolved, err := q.ResolveReports(r.Context(), database.ResolveReportsParams{
+		ChirpID:    chirpID,
+		Resolution: sql.NullString{String: resolution, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	moderatorID, ok := actingUser(r.Context())
+	action, err := q.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:     uuid.NullUUID{UUID: moderatorID, Valid: ok},
+		Action:          params.Action,
+		ChirpID:         uuid.NullUUID{UUID: chirpID, Valid: true},
+		UserID:          uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Reason:          params.Reason,
+		ReportsResolved: int32(resolved),
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	loggerFrom(r.Context()).Info("Moderation action taken", "action", action.Action, "chirp_id", chirpID,
+		"author_id", chirp.UserID, "reports_resolved", resolved)
+	respondWithJSON(w, http.StatusOK, toModerationAction(action))
+}
+
+// moderationActionsHandler lists the audit trail, newest first.
+func (cfg *apiConfig) moderationActionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r, 50, 200)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	actions, err := cfg.database.ListModerationActions(r.Context(), limit)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	response := make([]ModerationAction, len(actions))
+	for i, a := range actions {
+		response[i] = toModerationAction(a)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
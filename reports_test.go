@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func TestModerateChirpValidation(t *testing.T) {
+	cfg := &apiConfig{}
+	testCases := []struct {
+		name  string
+		path  string
+		body  string
+		field string
+	}{
+		{"bad chirp id", "not-a-uuid", `{"action":"hide"}`, "chirpID"},
+		{"missing action", "6f1c2a44-3a59-4c39-9fb4-8d5b0a8e2c11", `{}`, "action"},
+		{"unknown action", "6f1c2a44-3a59-4c39-9fb4-8d5b0a8e2c11", `{"action":"ban"}`, "action"},
+		{"until without suspend", "6f1c2a44-3a59-4c39-9fb4-8d5b0a8e2c11", `{"action":"hide","suspend_until":"2999-01-01T00:00:00Z"}`, "suspend_until"},
+		{"until in the past", "6f1c2a44-3a59-4c39-9fb4-8d5b0a8e2c11", `{"action":"suspend","suspend_until":"2000-01-01T00:00:00Z"}`, "suspend_until"},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/admin/moderation/chirps/"+tc.path+"/actions", strings.NewReader(tc.body))
+		req.SetPathValue("chirpID", tc.path)
+		rec := httptest.NewRecorder()
+		cfg.moderateChirpHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, expected %d", tc.name, rec.Code, http.StatusBadRequest)
+			continue
+		}
+		var p problem.Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: decoding problem: %v", tc.name, err)
+		}
+		if len(p.Errors) != 1 || p.Errors[0].Field != tc.field {
+			t.Errorf("%s: errors = %+v, expected one for %s", tc.name, p.Errors, tc.field)
+		}
+	}
+}
+
+func TestQueryLimit(t *testing.T) {
+	testCases := []struct {
+		query   string
+		want    int32
+		wantErr bool
+	}{
+		{"", 50, false},
+		{"?limit=10", 10, false},
+		{"?limit=200", 200, false},
+		{"?limit=0", 0, true},
+		{"?limit=201", 0, true},
+		{"?limit=ten", 0, true},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/moderation/queue"+tc.query, nil)
+		got, err := queryLimit(req, 50, 200)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("queryLimit(%q) = %d, %v; expected %d, error %v", tc.query, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestDismissedStatus(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		status    string
+		publishAt sql.NullTime
+		expected  string
+	}{
+		{"held", chirpHeld, sql.NullTime{}, chirpPublished},
+		{"hidden", chirpHidden, sql.NullTime{}, chirpPublished},
+		{"scheduled", chirpScheduled, sql.NullTime{Time: now.Add(time.Hour), Valid: true}, chirpScheduled},
+		{"hidden while scheduled", chirpHidden, sql.NullTime{Time: now.Add(time.Hour), Valid: true}, chirpScheduled},
+		{"held past its publish time", chirpHeld, sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, chirpPublished},
+	}
+	for _, tc := range testCases {
+		chirp := database.Chirp{Status: tc.status, PublishAt: tc.publishAt}
+		if got := dismissedStatus(chirp, now); got != tc.expected {
+			t.Errorf("%s: dismissedStatus() = %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
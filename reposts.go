@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/lib/pq"
+)
+
+// A repost shares an existing chirp and is counted on it; a user reposts a
+// chirp at most once. A quote is a new chirp whose quoted_chirp_id names
+// another chirp, which responses embed as quoted_chirp when the viewer
+// can see it. Deleting a chirp removes its reposts, while quotes of it
+// keep their quoted_chirp_id and simply lose the embedded chirp.
+
+func (cfg *apiConfig) repostHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	_, err = cfg.database.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, problem.NotFound("Chirp not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	err = cfg.database.CreateRepost(r.Context(), database.CreateRepostParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		respondWithError(w, r, fromMissingChirp(err))
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (cfg *apiConfig) unrepostHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	n, err := cfg.database.DeleteRepost(r.Context(), database.DeleteRepostParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if n == 0 {
+		respondWithError(w, r, problem.NotFound("Repost not found"))
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// fromMissingChirp reports a chirp deleted between the visibility check
+// and the insert as not found.
+func fromMissingChirp(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return problem.NotFound("Chirp not found")
+	}
+	return problem.Internal(err)
+}
+
+// quoteTarget checks the quoted_chirp_id of a new chirp, which must name a
+// chirp the author can see.
+func (cfg *apiConfig) quoteTarget(ctx context.Context, userID uuid.UUID, raw string) (uuid.NullUUID, error) {
+	if strings.TrimSpace(raw) == "" {
+		return uuid.NullUUID{}, nil
+	}
+	// The validate tag has already checked the format.
+	id := uuid.MustParse(raw)
+	_, err := cfg.database.GetVisibleChirp(ctx, database.GetVisibleChirpParams{ID: id, ViewerID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, problem.Validation(problem.FieldError{
+			Field:   "quoted_chirp_id",
+			Code:    "unavailable",
+			Message: "must be a chirp you can see",
+		})
+	}
+	if err != nil {
+		return uuid.NullUUID{}, problem.Internal(err)
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// decorateChirps fills in what chirp responses carry beyond the chirp
+// row: media, repost counts and the quoted chirp, as seen by viewerID.
+func (cfg *apiConfig) decorateChirps(ctx context.Context, viewerID uuid.UUID, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	if err := cfg.withMedia(ctx, chirps); err != nil {
+		return err
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	var quotedIDs []uuid.UUID
+	for i, c := range chirps {
+		ids[i] = c.ID
+		if c.QuotedChirpID != nil {
+			quotedIDs = append(quotedIDs, *c.QuotedChirpID)
+		}
+	}
+
+	counts, err := cfg.database.CountReposts(ctx, database.CountRepostsParams{ViewerID: viewerID, ChirpIds: ids})
+	if err != nil {
+		return problem.Internal(err)
+	}
+	byChirp := make(map[uuid.UUID]database.CountRepostsRow, len(counts))
+	for _, c := range counts {
+		byChirp[c.ChirpID] = c
+	}
+	for i := range chirps {
+		chirps[i].RepostCount = byChirp[chirps[i].ID].Reposts
+		chirps[i].Reposted = byChirp[chirps[i].ID].Reposted
+	}
+
+	if len(quotedIDs) == 0 {
+		return nil
+	}
+	rows, err := cfg.database.ListVisibleChirps(ctx, database.ListVisibleChirpsParams{Ids: quotedIDs, ViewerID: viewerID})
+	if err != nil {
+		return problem.Internal(err)
+	}
+	quoted := make([]Chirp, len(rows))
+	for i, c := range rows {
+		quoted[i] = toChirp(c)
+	}
+	if err := cfg.withMedia(ctx, quoted); err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*Chirp, len(quoted))
+	for i := range quoted {
+		byID[quoted[i].ID] = &quoted[i]
+	}
+	for i := range chirps {
+		if id := chirps[i].QuotedChirpID; id != nil {
+			chirps[i].QuotedChirp = byID[*id]
+		}
+	}
+	return nil
+}
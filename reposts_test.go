@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func TestQuoteTargetWithoutQuote(t *testing.T) {
+	cfg := &apiConfig{}
+	for _, raw := range []string{"", "  "} {
+		id, err := cfg.quoteTarget(context.Background(), uuid.New(), raw)
+		if err != nil || id.Valid {
+			t.Errorf("quoteTarget(%q) = %v, %v; expected no quote", raw, id, err)
+		}
+	}
+}
+
+func TestQuoteTarget(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	viewerID, _ := testUser(t, cfg, "quoter@example.com")
+	authorID, _ := testUser(t, cfg, "author@example.com")
+	blockerID, _ := testUser(t, cfg, "blocker@example.com")
+	bannedID, _ := testUser(t, cfg, "banned@example.com")
+
+	visible := testChirp(t, cfg, authorID, "quote me", uuid.Nil)
+	fromBlocker := testChirp(t, cfg, blockerID, "not for you", uuid.Nil)
+	fromBanned := testChirp(t, cfg, bannedID, "gone soon", uuid.Nil)
+	if err := cfg.database.CreateBlock(ctx, database.CreateBlockParams{BlockerID: blockerID, BlockedID: viewerID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := suspendUser(ctx, cfg.database, bannedID, nil, "spam"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := cfg.quoteTarget(ctx, viewerID, visible.ID.String())
+	if err != nil || id.UUID != visible.ID {
+		t.Errorf("visible chirp: got %v, %v", id, err)
+	}
+	for name, chirpID := range map[string]uuid.UUID{
+		"blocked author": fromBlocker.ID,
+		"banned author":  fromBanned.ID,
+		"missing chirp":  uuid.New(),
+	} {
+		_, err := cfg.quoteTarget(ctx, viewerID, chirpID.String())
+		p := problem.From(err)
+		if p.Code != problem.CodeValidationFailed || len(p.Fields) != 1 || p.Fields[0].Code != "unavailable" {
+			t.Errorf("%s: err = %v, expected quoted_chirp_id unavailable", name, err)
+		}
+	}
+}
+
+func TestDecorateChirpsQuotes(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	viewerID, _ := testUser(t, cfg, "reader@example.com")
+	authorID, _ := testUser(t, cfg, "original@example.com")
+	quoterID, _ := testUser(t, cfg, "quoter@example.com")
+
+	original := testChirp(t, cfg, authorID, "the original", uuid.Nil)
+	quote := testChirp(t, cfg, quoterID, "look at this", original.ID)
+	quotedFor := func() *Chirp {
+		t.Helper()
+		chirps := []Chirp{toChirp(quote)}
+		if err := cfg.decorateChirps(ctx, viewerID, chirps); err != nil {
+			t.Fatal(err)
+		}
+		if chirps[0].QuotedChirpID == nil || *chirps[0].QuotedChirpID != original.ID {
+			t.Errorf("quoted_chirp_id = %v, expected %s", chirps[0].QuotedChirpID, original.ID)
+		}
+		return chirps[0].QuotedChirp
+	}
+
+	if q := quotedFor(); q == nil || q.Body != "the original" {
+		t.Fatalf("visible original: quoted_chirp = %+v", q)
+	}
+	if err := cfg.database.SetChirpStatus(ctx, database.SetChirpStatusParams{ID: original.ID, Status: chirpHidden}); err != nil {
+		t.Fatal(err)
+	}
+	if q := quotedFor(); q != nil {
+		t.Errorf("hidden original: quoted_chirp = %+v, expected none", q)
+	}
+	if err := cfg.database.DeleteChirp(ctx, original.ID); err != nil {
+		t.Fatal(err)
+	}
+	if q := quotedFor(); q != nil {
+		t.Errorf("deleted original: quoted_chirp = %+v, expected none", q)
+	}
+}
+
+func TestRepost(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	authorID, _ := testUser(t, cfg, "poster@example.com")
+	reposterID, token := testUser(t, cfg, "reposter@example.com")
+	chirp := testChirp(t, cfg, authorID, "worth sharing", uuid.Nil)
+
+	for i := range 2 {
+		if rec := serve(cfg.repostHandler, http.MethodPost, token, "", "chirpID", chirp.ID.String()); rec.Code != http.StatusNoContent {
+			t.Fatalf("repost %d: status = %d: %s", i+1, rec.Code, rec.Body)
+		}
+	}
+	for viewer, reposted := range map[uuid.UUID]bool{reposterID: true, authorID: false} {
+		chirps := []Chirp{toChirp(chirp)}
+		if err := cfg.decorateChirps(ctx, viewer, chirps); err != nil {
+			t.Fatal(err)
+		}
+		if chirps[0].RepostCount != 1 || chirps[0].Reposted != reposted {
+			t.Errorf("viewer %s: repost_count = %d, reposted = %v; expected 1, %v", viewer, chirps[0].RepostCount, chirps[0].Reposted, reposted)
+		}
+	}
+
+	if rec := serve(cfg.repostHandler, http.MethodPost, token, "", "chirpID", uuid.NewString()); rec.Code != http.StatusNotFound {
+		t.Errorf("reposting a missing chirp: status = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := serve(cfg.unrepostHandler, http.MethodDelete, token, "", "chirpID", chirp.ID.String()); rec.Code != http.StatusNoContent {
+		t.Errorf("undoing the repost: status = %d, expected %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := serve(cfg.unrepostHandler, http.MethodDelete, token, "", "chirpID", chirp.ID.String()); rec.Code != http.StatusNotFound {
+		t.Errorf("undoing it again: status = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/jobs"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
+)
+
+// Scheduled chirps are stored with status scheduled, which every read
+// skips, and published by a publish_chirp job enqueued in the same
+// transaction. The job may run more than once or after the chirp was
+// rescheduled or cancelled; PublishScheduledChirp only publishes a chirp
+// that is still scheduled and due, so extra runs do nothing.
+//
+// Moderation runs when a chirp is scheduled or edited. A held chirp
+// leaves the schedule and waits for a moderator instead; if its reports
+// are dismissed before publish_at, it goes back on the schedule.
+
+const (
+	jobPublishChirp = "publish_chirp"
+
+	// maxScheduleAhead is how far in the future a chirp may be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+)
+
+type publishChirpPayload struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// parsePublishAt checks a requested publish_at and converts it to UTC:
+// publish_at is a TIMESTAMP column, which would drop the offset and
+// publish the chirp late or early. A nil t is not scheduled.
+func parsePublishAt(t *time.Time) (sql.NullTime, error) {
+	if t == nil {
+		return sql.NullTime{}, nil
+	}
+	now := time.Now()
+	switch {
+	case !t.After(now):
+		return sql.NullTime{}, problem.Validation(problem.FieldError{Field: "publish_at", Code: "out_of_range", Message: "must be in the future"})
+	case t.After(now.Add(maxScheduleAhead)):
+		return sql.NullTime{}, problem.Validation(problem.FieldError{Field: "publish_at", Code: "out_of_range", Message: "must be within a year"})
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// schedulePublish enqueues the job that publishes chirp at its publish_at.
+// Each publish time gets its own job so rescheduling never waits on a
+// stale one.
+func schedulePublish(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	_, err := jobs.Enqueue(ctx, q, jobPublishChirp, publishChirpPayload{ChirpID: chirp.ID}, jobs.EnqueueOptions{
+		RunAt:     chirp.PublishAt.Time,
+		DedupeKey: fmt.Sprintf("%s:%s:%d", jobPublishChirp, chirp.ID, chirp.PublishAt.Time.Unix()),
+	})
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+func (cfg *apiConfig) publishChirpJob(ctx context.Context, job database.Job) error {
+	var payload publishChirpPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	n, err := cfg.database.PublishScheduledChirp(ctx, payload.ChirpID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Published scheduled chirp", "chirp_id", payload.ChirpID)
+	}
+	return nil
+}
+
+// listScheduledHandler lists the user's scheduled chirps, soonest first.
+func (cfg *apiConfig) listScheduledHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	rows, err := cfg.database.ListScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	chirps := make([]Chirp, len(rows))
+	for i, c := range rows {
+		chirps[i] = toChirp(c)
+	}
+	if err := cfg.decorateChirps(r.Context(), userID, chirps); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// updateScheduledHandler changes the body or publish time of a chirp that
+// has not been published yet.
+func (cfg *apiConfig) updateScheduledHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	publishAt, err := parsePublishAt(params.PublishAt)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+
+	chirp, err := q.GrabChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (chirp.UserID != userID || chirp.Status != chirpScheduled)) {
+		respondWithError(w, r, problem.NotFound("Scheduled chirp not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+
+	arg := database.UpdateScheduledChirpParams{
+		Body:      chirp.Body,
+		Status:    chirpScheduled,
+		PublishAt: chirp.PublishAt,
+		ID:        chirpID,
+		UserID:    userID,
+	}
+	if params.Body != nil {
+		body, status, err := cfg.screenChirp(r.Context(), *params.Body)
+		if err != nil {
+			respondWithError(w, r, err)
+			return
+		}
+		arg.Body = body
+		if status == chirpHeld {
+			arg.Status = chirpHeld
+		}
+	}
+	if publishAt.Valid {
+		arg.PublishAt = publishAt
+	}
+	updated, err := q.UpdateScheduledChirp(r.Context(), arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Published between the read and the update.
+		respondWithError(w, r, problem.NotFound("Scheduled chirp not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if updated.Status == chirpScheduled && !updated.PublishAt.Time.Equal(chirp.PublishAt.Time) {
+		if err := schedulePublish(r.Context(), q, updated); err != nil {
+			respondWithError(w, r, problem.Internal(err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	response := []Chirp{toChirp(updated)}
+	if err := cfg.decorateChirps(r.Context(), userID, response); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response[0])
+}
+
+// cancelScheduledHandler deletes a chirp that has not been published yet.
+// Its pending publish job finds nothing to do.
+func (cfg *apiConfig) cancelScheduledHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	chirpID, err := pathUUID(r, "chirpID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	n, err := cfg.database.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     chirpID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if n == 0 {
+		respondWithError(w, r, problem.NotFound("Scheduled chirp not found"))
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func TestParsePublishAt(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name string
+		at   time.Time
+		ok   bool
+	}{
+		{"next hour", now.Add(time.Hour), true},
+		{"in eleven months", now.Add(330 * 24 * time.Hour), true},
+		{"past", now.Add(-time.Minute), false},
+		{"too far ahead", now.Add(maxScheduleAhead + time.Hour), false},
+	}
+	for _, tc := range testCases {
+		_, err := parsePublishAt(&tc.at)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.ok && problem.From(err).Code != problem.CodeValidationFailed {
+			t.Errorf("%s: err = %v, expected a validation problem", tc.name, err)
+		}
+	}
+
+	if got, err := parsePublishAt(nil); err != nil || got.Valid {
+		t.Errorf("parsePublishAt(nil) = %v, %v; expected an invalid NullTime", got, err)
+	}
+}
+
+func TestParsePublishAtConvertsToUTC(t *testing.T) {
+	// Noon at +02:00 is 10:00 UTC; stored as noon it would publish two
+	// hours late.
+	zone := time.FixedZone("", 2*60*60)
+	noon := time.Now().In(zone).Add(48 * time.Hour)
+	noon = time.Date(noon.Year(), noon.Month(), noon.Day(), 12, 0, 0, 0, zone)
+
+	got, err := parsePublishAt(&noon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Time.Location() != time.UTC || got.Time.Hour() != 10 || !got.Time.Equal(noon) {
+		t.Errorf("parsePublishAt(%v) = %v, expected 10:00 UTC", noon, got.Time)
+	}
+}
@@ -1,28 +1,134 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
 
 -- name: RetrieveChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::UUID)
+    OR (blocks.blocker_id = sqlc.arg(viewer_id)::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::UUID AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC;
 
 -- name: GrabChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
 WHERE id = $1;
 
+-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE id = sqlc.arg(id) AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::UUID)
+    OR (blocks.blocker_id = sqlc.arg(viewer_id)::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::UUID AND mutes.muted_id = chirps.user_id
+);
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps 
 WHERE id = $1;
 
 -- name: RetrieveChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::UUID)
+    OR (blocks.blocker_id = sqlc.arg(viewer_id)::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::UUID AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at ASC;
+
+-- name: ListChirpsByUser :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, quoted_chirp_id FROM chirps
 WHERE user_id = $1
-ORDER BY created_at ASC;
\ No newline at end of file
+ORDER BY created_at ASC;
+
+-- name: SeedChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES ($1, $2, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirps :execrows
+DELETE FROM chirps;
+
+-- name: SetChirpStatus :exec
+UPDATE chirps
+SET status = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status = 'scheduled'
+ORDER BY publish_at ASC;
+
+-- name: UpdateScheduledChirp :one
+UPDATE chirps
+SET body = sqlc.arg(body), status = sqlc.arg(status), publish_at = sqlc.arg(publish_at), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND status = 'scheduled'
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status = 'scheduled';
+
+-- name: PublishScheduledChirp :execrows
+UPDATE chirps
+SET status = 'published', created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled' AND publish_at <= NOW();
+
+-- name: ListVisibleChirps :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::UUID[]) AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.suspended_at IS NOT NULL AND users.suspended_until IS NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::UUID)
+    OR (blocks.blocker_id = sqlc.arg(viewer_id)::UUID AND blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::UUID AND mutes.muted_id = chirps.user_id
+);
@@ -0,0 +1,59 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, status, max_attempts, run_at, dedupe_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'pending',
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (dedupe_key) WHERE status IN ('pending', 'running') DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running', locked_at = NOW(), updated_at = NOW(), attempts = attempts + 1
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE status = 'pending' AND run_at <= NOW()
+    ORDER BY run_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'done', locked_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, updated_at = NOW(), run_at = $1, last_error = $2
+WHERE id = $3;
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, updated_at = NOW(), last_error = $1
+WHERE id = $2;
+
+-- name: RequeueStaleJobs :many
+-- Attempts are counted when a job is claimed, so a job whose worker died
+-- on its last attempt is dead-lettered instead of running forever. The
+-- cutoff is computed from NOW() like locked_at, so the host's clock and
+-- time zone don't matter.
+UPDATE jobs
+SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+    locked_at = NULL,
+    updated_at = NOW(),
+    last_error = 'job lock expired'
+WHERE status = 'running'
+AND locked_at < NOW() - make_interval(secs => sqlc.arg(lock_timeout_seconds)::DOUBLE PRECISION)
+RETURNING *;
+
+-- name: DeleteJobs :execrows
+DELETE FROM jobs;
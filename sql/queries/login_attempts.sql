@@ -0,0 +1,34 @@
+-- name: GetLoginAttempts :many
+SELECT * FROM login_attempts
+WHERE key = ANY(sqlc.arg(keys)::TEXT[]);
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (key, failures, first_failed_at, last_failed_at, locked_until)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now), sqlc.arg(now), NULL)
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN login_attempts.first_failed_at < sqlc.arg(window_start) THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    first_failed_at = CASE
+        WHEN login_attempts.first_failed_at < sqlc.arg(window_start) THEN sqlc.arg(now)
+        ELSE login_attempts.first_failed_at
+    END,
+    last_failed_at = sqlc.arg(now)
+RETURNING *;
+
+-- name: LockLoginAttempts :exec
+UPDATE login_attempts
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1);
+
+-- name: DeleteLoginAttempts :execrows
+DELETE FROM login_attempts;
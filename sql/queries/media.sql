@@ -0,0 +1,45 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, kind, content_type, size_bytes, width, height)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media
+WHERE id = $1;
+
+-- name: AttachMedia :execrows
+UPDATE media
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND kind = 'attachment' AND chirp_id IS NULL;
+
+-- name: ListMediaForChirps :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+ORDER BY chirp_id, position;
+
+-- name: ListMediaByUser :many
+SELECT * FROM media
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListUnattachedMedia :many
+SELECT id FROM media
+WHERE kind = 'attachment' AND chirp_id IS NULL AND created_at < $1;
+
+-- name: DeleteOtherAvatars :many
+DELETE FROM media
+WHERE user_id = $1 AND kind = 'avatar' AND id <> $2
+RETURNING id;
+
+-- name: DeleteMedia :exec
+DELETE FROM media
+WHERE id = $1;
@@ -0,0 +1,21 @@
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, chirp_id, user_id, reason, reports_resolved)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: DeleteModerationActions :execrows
+DELETE FROM moderation_actions;
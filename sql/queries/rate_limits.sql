@@ -0,0 +1,24 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+VALUES (
+    sqlc.arg(key),
+    sqlc.arg(burst)::DOUBLE PRECISION - 1,
+    true,
+    NOW()
+)
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST(sqlc.arg(burst)::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (NOW() - rate_limit_buckets.updated_at)) * sqlc.arg(rate)::DOUBLE PRECISION) >= 1
+        THEN LEAST(sqlc.arg(burst)::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (NOW() - rate_limit_buckets.updated_at)) * sqlc.arg(rate)::DOUBLE PRECISION) - 1
+        ELSE LEAST(sqlc.arg(burst)::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (NOW() - rate_limit_buckets.updated_at)) * sqlc.arg(rate)::DOUBLE PRECISION)
+    END,
+    allowed = LEAST(sqlc.arg(burst)::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (NOW() - rate_limit_buckets.updated_at)) * sqlc.arg(rate)::DOUBLE PRECISION) >= 1,
+    updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
+
+-- name: DeleteRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets;
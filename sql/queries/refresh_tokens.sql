@@ -19,3 +19,22 @@ WHERE token = $1;
 UPDATE refresh_tokens
 SET updated_at = $1, revoked_at = $2
 WHERE token = $3;
+
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < NOW() OR revoked_at IS NOT NULL;
+
+-- name: ListRefreshTokensByUser :many
+SELECT created_at, expires_at, revoked_at
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteRefreshTokens :execrows
+DELETE FROM refresh_tokens;
@@ -0,0 +1,40 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: ListBlocks :many
+SELECT blocked_id, created_at FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: ListMutes :many
+SELECT muted_id, created_at FROM mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListFollows :many
+SELECT followed_id, created_at FROM follows
+WHERE follower_id = $1
+ORDER BY created_at DESC;
+
+-- name: SeedFollow :execrows
+INSERT INTO follows (follower_id, followed_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollows :execrows
+DELETE FROM follows;
@@ -0,0 +1,37 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET resolved_at = NOW(), updated_at = NOW(), resolution = $2
+WHERE chirp_id = $1 AND resolved_at IS NULL;
+
+-- name: ListModerationQueue :many
+SELECT
+    c.id,
+    c.created_at,
+    c.body,
+    c.user_id,
+    c.status,
+    COUNT(r.id) AS report_count,
+    COALESCE(array_agg(DISTINCT r.reason) FILTER (WHERE r.id IS NOT NULL), '{}')::TEXT[] AS reasons,
+    COALESCE(MIN(r.created_at), c.created_at)::TIMESTAMP AS queued_at
+FROM chirps c
+LEFT JOIN reports r ON r.chirp_id = c.id AND r.resolved_at IS NULL
+WHERE c.status = 'held' OR r.id IS NOT NULL
+GROUP BY c.id
+ORDER BY queued_at ASC
+LIMIT $1;
+
+-- name: DeleteReports :execrows
+DELETE FROM reports;
@@ -0,0 +1,22 @@
+-- name: CreateRepost :exec
+INSERT INTO reposts (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRepost :execrows
+DELETE FROM reposts
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: CountReposts :many
+SELECT
+    chirp_id,
+    COUNT(*) AS reposts,
+    BOOL_OR(user_id = sqlc.arg(viewer_id)::UUID)::BOOLEAN AS reposted
+FROM reposts
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+GROUP BY chirp_id;
+
+-- name: ListRepostsByUser :many
+SELECT chirp_id, created_at FROM reposts
+WHERE user_id = $1
+ORDER BY created_at DESC;
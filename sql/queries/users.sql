@@ -9,7 +9,7 @@ VALUES (
 )
 RETURNING *;
 
--- name: DeleteUsers :exec
+-- name: DeleteUsers :execrows
 DELETE FROM users;
 
 
@@ -35,4 +35,52 @@ WHERE id = $1;
 
 -- name: IsUserChirpyRed :one
 SELECT is_chirpy_red FROM users
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserRole :exec
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SeedUser :execrows
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+VALUES ($1, $2, $2, $3, $4, $5)
+ON CONFLICT DO NOTHING;
+
+-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_at = NOW(), suspended_until = $2, suspension_reason = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND suspended_at IS NOT NULL;
+
+-- name: GetUserSuspension :one
+SELECT suspended_at, suspended_until, suspension_reason FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE lower(handle) = lower(sqlc.arg(handle));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = COALESCE(sqlc.narg(handle), handle),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio),
+    avatar_url = COALESCE(sqlc.narg(avatar_url), avatar_url),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
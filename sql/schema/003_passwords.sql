@@ -2,4 +2,6 @@
 ALTER TABLE users
 ADD hashed_password TEXT NOT NULL DEFAULT 'unset';
 
-
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN hashed_password;
@@ -2,3 +2,6 @@
 ALTER TABLE users
 ADD is_chirpy_red BOOLEAN DEFAULT false; 
 
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
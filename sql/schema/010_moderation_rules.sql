@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE moderation_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('word', 'regex')),
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'hold', 'reject')),
+    UNIQUE (kind, pattern)
+);
+
+INSERT INTO moderation_rules (id, created_at, updated_at, kind, pattern, action)
+VALUES
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'kerfuffle', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'sharbert', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'fornax', 'mask');
+
+ALTER TABLE chirps
+ADD status TEXT NOT NULL DEFAULT 'published'
+CHECK (status IN ('published', 'held'));
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN status;
+
+DROP TABLE moderation_rules;
@@ -0,0 +1,55 @@
+-- +goose Up
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP,
+    resolution TEXT CHECK (resolution IN ('dismissed', 'actioned'))
+);
+
+CREATE UNIQUE INDEX reports_open_by_reporter ON reports (chirp_id, reporter_id)
+WHERE resolved_at IS NULL;
+
+-- Audit trail of moderation decisions. Targets are not foreign keys so the
+-- record survives the chirp or user being deleted.
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    chirp_id UUID,
+    user_id UUID,
+    reason TEXT NOT NULL DEFAULT '',
+    reports_resolved INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX moderation_actions_created_at ON moderation_actions (created_at);
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden'));
+
+ALTER TABLE users
+ADD suspended_at TIMESTAMP,
+ADD suspended_until TIMESTAMP,
+ADD suspension_reason TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN suspended_at,
+DROP COLUMN suspended_until,
+DROP COLUMN suspension_reason;
+
+UPDATE chirps SET status = 'held' WHERE status = 'hidden';
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held'));
+
+DROP TABLE moderation_actions;
+DROP TABLE reports;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE media (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('attachment', 'avatar')),
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX media_chirp_id ON media (chirp_id, position);
+CREATE INDEX media_unattached ON media (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD publish_at TIMESTAMP,
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden', 'scheduled'));
+
+CREATE INDEX chirps_scheduled ON chirps (user_id, publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_scheduled;
+
+DELETE FROM chirps WHERE status = 'scheduled';
+
+ALTER TABLE chirps
+DROP COLUMN publish_at,
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden'));
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE reposts (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX reposts_chirp_id ON reposts (chirp_id);
+
+-- No foreign key: a quote outlives the chirp it quotes and reports it as
+-- unavailable.
+ALTER TABLE chirps
+ADD quoted_chirp_id UUID;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN quoted_chirp_id;
+
+DROP TABLE reposts;
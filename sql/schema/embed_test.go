@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+func TestEveryMigrationHasDown(t *testing.T) {
+	names, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "-- +goose Down") {
+			t.Errorf("%s has no Down section", name)
+		}
+	}
+}
+
+// TestMigrationsUpDown applies every migration, rolls them all back and
+// applies them again. It needs an empty database in CHIRPY_TEST_DB_URL.
+func TestMigrationsUpDown(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	provider, err := NewProvider(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for round := 1; round <= 2; round++ {
+		if _, err := provider.Up(ctx); err != nil {
+			t.Fatalf("round %d: up: %v", round, err)
+		}
+		if version, err := provider.GetDBVersion(ctx); err != nil || version != latest {
+			t.Fatalf("round %d: version after up = %d, %v; want %d", round, version, err, latest)
+		}
+		if _, err := provider.DownTo(ctx, 0); err != nil {
+			t.Fatalf("round %d: down: %v", round, err)
+		}
+		if version, err := provider.GetDBVersion(ctx); err != nil || version != 0 {
+			t.Fatalf("round %d: version after down = %d, %v; want 0", round, version, err)
+		}
+	}
+}
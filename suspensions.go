@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iahta/chirpy/internal/auth"
+	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
+)
+
+// suspension is an account's suspension state. A suspension without an
+// end is a ban, which also hides the user's chirps.
+type suspension struct {
+	at     sql.NullTime
+	until  sql.NullTime
+	reason sql.NullString
+}
+
+func (s suspension) active(now time.Time) bool {
+	return s.at.Valid && (!s.until.Valid || now.Before(s.until.Time))
+}
+
+func (s suspension) banned() bool {
+	return s.at.Valid && !s.until.Valid
+}
+
+// err is the problem returned to a suspended account.
+func (s suspension) err() error {
+	detail := "This account has been suspended"
+	if s.until.Valid {
+		detail += " until " + s.until.Time.UTC().Format(time.RFC3339)
+	}
+	if s.reason.Valid {
+		detail += ": " + s.reason.String
+	}
+	return problem.New(http.StatusForbidden, problem.CodeAccountSuspended, detail)
+}
+
+func userSuspension(u database.User) suspension {
+	return suspension{at: u.SuspendedAt, until: u.SuspendedUntil, reason: u.SuspensionReason}
+}
+
+// checkSuspended returns a problem if userID is suspended or no longer
+// exists, so that outstanding access tokens stop working.
+func (cfg *apiConfig) checkSuspended(ctx context.Context, userID uuid.UUID) error {
+	row, err := cfg.database.GetUserSuspension(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return problem.Unauthorized("Account no longer exists")
+	}
+	if err != nil {
+		return problem.Internal(err)
+	}
+	s := suspension{at: row.SuspendedAt, until: row.SuspendedUntil, reason: row.SuspensionReason}
+	if s.active(time.Now()) {
+		return s.err()
+	}
+	return nil
+}
+
+// authenticateRole validates the request's bearer access token and checks
+// that its user is not suspended.
+func (cfg *apiConfig) authenticateRole(r *http.Request) (uuid.UUID, auth.Role, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, "", problem.Unauthorized("Missing bearer token")
+	}
+	_, span := tracing.Tracer().Start(r.Context(), "auth.ValidateJWT")
+	userID, role, err := auth.ValidateJWTRole(token, cfg.jwtSecret)
+	span.End()
+	if err != nil {
+		return uuid.Nil, "", problem.Unauthorized("Invalid or expired access token")
+	}
+	setRequestUser(r.Context(), userID)
+	if err := cfg.checkSuspended(r.Context(), userID); err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, role, nil
+}
+
+func (cfg *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	userID, _, err := cfg.authenticateRole(r)
+	return userID, err
+}
+
+// optionalViewer authenticates the request if it carries an Authorization
+// header and returns uuid.Nil for anonymous requests, which match no
+// blocks or mutes.
+func (cfg *apiConfig) optionalViewer(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	return cfg.authenticate(r)
+}
+
+// suspendUser suspends userID until until, or indefinitely if until is nil,
+// and revokes its refresh tokens. It returns sql.ErrNoRows if there is no
+// such user.
+func suspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, until *time.Time, reason string) error {
+	n, err := q.SuspendUser(ctx, database.SuspendUserParams{
+		ID:               userID,
+		SuspendedUntil:   nullTime(until),
+		SuspensionReason: sql.NullString{String: reason, Valid: reason != ""},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = q.RevokeUserRefreshTokens(ctx, userID)
+	return err
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func (cfg *apiConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string     `json:"reason" validate:"max=500"`
+		Until  *time.Time `json:"until"`
+	}
+	userID, err := pathUUID(r, "userID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if params.Until != nil && !params.Until.After(time.Now()) {
+		respondWithError(w, r, problem.Validation(problem.FieldError{
+			Field:   "until",
+			Code:    "out_of_range",
+			Message: "must be in the future",
+		}))
+		return
+	}
+	if params.Until != nil {
+		// suspended_until is a TIMESTAMP, which would drop the offset.
+		until := params.Until.UTC()
+		params.Until = &until
+	}
+	cfg.recordUserAction(w, r, actionSuspend, userID, params.Reason, func(q *database.Queries) error {
+		return suspendUser(r.Context(), q, userID, params.Until, params.Reason)
+	})
+}
+
+func (cfg *apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason" validate:"max=500"`
+	}
+	userID, err := pathUUID(r, "userID")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	params := parameters{}
+	if err := request.Decode(w, r, &params); err != nil && !errors.Is(err, request.ErrEmptyBody) {
+		respondWithError(w, r, err)
+		return
+	}
+	cfg.recordUserAction(w, r, actionUnsuspend, userID, params.Reason, func(q *database.Queries) error {
+		n, err := q.UnsuspendUser(r.Context(), userID)
+		if err == nil && n == 0 {
+			return sql.ErrNoRows
+		}
+		return err
+	})
+}
+
+// recordUserAction runs apply and records it in the moderation audit trail
+// in one transaction. apply returns sql.ErrNoRows when there is nothing to
+// act on.
+func (cfg *apiConfig) recordUserAction(w http.ResponseWriter, r *http.Request, action string, userID uuid.UUID, reason string, apply func(*database.Queries) error) {
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+
+	err = apply(q)
+	if errors.Is(err, sql.ErrNoRows) {
+		detail := "User not found"
+		if action == actionUnsuspend {
+			detail = "User not found or not suspended"
+		}
+		respondWithError(w, r, problem.NotFound(detail))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	moderatorID, ok := actingUser(r.Context())
+	recorded, err := q.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: ok},
+		Action:      action,
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:      reason,
+	})
+	if err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, problem.Internal(err))
+		return
+	}
+	loggerFrom(r.Context()).Info("Moderation action taken", "action", action, "target_user_id", userID)
+	respondWithJSON(w, http.StatusOK, toModerationAction(recorded))
+}
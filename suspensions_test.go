@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iahta/chirpy/internal/problem"
+)
+
+func TestSuspensionActive(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	testCases := []struct {
+		name string
+		s    suspension
+		want bool
+	}{
+		{"not suspended", suspension{}, false},
+		{"banned", suspension{at: at}, true},
+		{"suspended until later", suspension{at: at, until: sql.NullTime{Time: now.Add(time.Minute), Valid: true}}, true},
+		{"suspension expired", suspension{at: at, until: sql.NullTime{Time: now.Add(-time.Minute), Valid: true}}, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.s.active(now); got != tc.want {
+			t.Errorf("%s: active = %v, expected %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSuspensionErr(t *testing.T) {
+	s := suspension{
+		at:     sql.NullTime{Time: time.Now(), Valid: true},
+		until:  sql.NullTime{Time: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+		reason: sql.NullString{String: "spam", Valid: true},
+	}
+	var p *problem.Error
+	if !errors.As(s.err(), &p) {
+		t.Fatalf("err() = %v, expected a *problem.Error", s.err())
+	}
+	if p.Status != http.StatusForbidden || p.Code != problem.CodeAccountSuspended {
+		t.Errorf("got %d %s, expected 403 %s", p.Status, p.Code, problem.CodeAccountSuspended)
+	}
+	if !strings.Contains(p.Detail, "2030-01-02T03:04:05Z") || !strings.Contains(p.Detail, "spam") {
+		t.Errorf("detail %q should mention the end and the reason", p.Detail)
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/iahta/chirpy/internal/auth"
 	"github.com/iahta/chirpy/internal/database"
+	"github.com/iahta/chirpy/internal/problem"
+	"github.com/iahta/chirpy/internal/request"
+	"github.com/iahta/chirpy/internal/tracing"
 )
 
 type User struct {
@@ -17,12 +20,14 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Role        string    `json:"role"`
+	Handle      *string   `json:"handle"`
 }
 
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string `json:"password" validate:"required,min=8,maxbytes=72"`
+		Email    string `json:"email" validate:"required,email"`
 	}
 	type response struct {
 		ID          uuid.UUID `json:"id"`
@@ -32,35 +37,23 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		IsChirpyRed bool      `json:"is_chirpy_red"`
 	}
 
-	authHeader, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondWithError(w, r, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authHeader, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid Credentials")
-		return
-	}
-
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		log.Printf("Error decoding json: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
-		return
-	}
-	if !isValidEmail(params.Email) {
-		log.Printf("Invalid email: %s", params.Email)
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+	if err := request.Decode(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
+	_, span := tracing.Tracer().Start(r.Context(), "auth.HashPassword")
 	newPassword, err := auth.HashPassword(params.Password)
+	span.End()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Unable to create new password")
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 
@@ -71,7 +64,7 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		ID:             userID,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Unable to update user profile")
+		respondWithError(w, r, fromUniqueViolation(err, "email", "Email is already registered"))
 		return
 	}
 
@@ -95,48 +88,60 @@ func (cfg *apiConfig) upgradeUser(w http.ResponseWriter, r *http.Request) {
 
 	authKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized request")
+		respondWithError(w, r, problem.Unauthorized("Missing API key"))
 		return
 	}
 
 	err = auth.ValidatePolkaKey(authKey, cfg.polkaKey)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized request")
+		respondWithError(w, r, problem.Unauthorized("Invalid API key"))
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		log.Printf("Error decoding json: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	if err := request.DecodeLoose(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
+	webhooks := cfg.metrics.WebhooksProcessed
 	if params.Event != "user.upgraded" {
+		webhooks.WithLabelValues("other", "ignored").Inc()
 		respondWithJSON(w, http.StatusNoContent, nil)
 		return
 	}
 	///convert to uuid
 	parsedUser, err := uuid.Parse(params.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid userID format. Ensure it is a valid UUID")
+		webhooks.WithLabelValues(params.Event, "failed").Inc()
+		respondWithError(w, r, problem.Validation(problem.FieldError{
+			Field:   "data.user_id",
+			Code:    "invalid_uuid",
+			Message: "must be a UUID",
+		}))
 		return
 	}
 
 	isAlreadyChirpyRed, err := cfg.database.IsUserChirpyRed(r.Context(), parsedUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		webhooks.WithLabelValues(params.Event, "failed").Inc()
+		respondWithError(w, r, problem.NotFound("User not found"))
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "User can't be found")
+		webhooks.WithLabelValues(params.Event, "failed").Inc()
+		respondWithError(w, r, problem.Internal(err))
 		return
 	}
 	if !isAlreadyChirpyRed.Bool {
 		err = cfg.database.UpgradeUserToRed(r.Context(), parsedUser)
 		if err != nil {
-			respondWithError(w, http.StatusNotFound, "User can't be found")
+			webhooks.WithLabelValues(params.Event, "failed").Inc()
+			respondWithError(w, r, problem.Internal(err))
 			return
 		}
 	}
 
+	webhooks.WithLabelValues(params.Event, "processed").Inc()
 	respondWithJSON(w, http.StatusNoContent, nil)
 
 }